@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang-watchlist/internal/backup"
+	"golang-watchlist/internal/config"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/repository"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	output := flag.String("o", "watchlist-"+time.Now().Format("20060102-150405")+".backup",
+		"file to write the backup to")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	database, err := db.Open(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read schema version: %v", err)
+	}
+	if version == 0 {
+		log.Fatal("Database has no schema yet; run the migrations first")
+	}
+
+	// Write next to the destination and rename once complete, so a failed
+	// backup never leaves a truncated archive behind under the real name.
+	file, err := os.CreateTemp(filepath.Dir(*output), filepath.Base(*output)+".*.tmp")
+	if err != nil {
+		log.Fatalf("Failed to create backup file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	header := backup.Header{SchemaVersion: version, Driver: cfg.Database.Driver}
+	count, trashed, err := backup.Write(ctx, file, repository.NewRecordRepository(database), header)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Failed to write backup: %v", err)
+	}
+	if err := os.Rename(file.Name(), *output); err != nil {
+		log.Fatalf("Failed to write backup: %v", err)
+	}
+
+	fmt.Printf("Backed up %d records, %d of them in the trash, at schema version %d to %s\n", count, trashed, version, *output)
+}
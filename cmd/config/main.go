@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"golang-watchlist/internal/config"
+	"log"
+	"os"
+)
+
+const usage = `usage: config <command> [flags]
+
+commands:
+  print       show the effective configuration, where each value came
+              from and any problems with it; secrets are redacted
+
+Flags are the same as the server's. Each source overrides the ones
+before it: defaults, the --config file, .env, environment variables and
+flags.`
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "print" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	cfg, err := config.Load(flags, os.Args[2:])
+	if cfg != nil {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang-watchlist/internal/config"
+	"golang-watchlist/internal/db"
+	"log"
+	"os"
+	"strconv"
+)
+
+const usage = `usage: migrate [flags] <command>
+
+commands:
+  up          apply all pending migrations
+  down [n]    revert the last n migrations (default 1)
+  status      list migrations and whether they are applied
+
+flags:`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	database, err := db.Open(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid step count %q", flag.Arg(1))
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
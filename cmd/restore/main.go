@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"golang-watchlist/internal/backup"
+	"golang-watchlist/internal/config"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/repository"
+	"log"
+	"os"
+)
+
+const usage = `usage: restore [flags] <backup file>
+
+Restores a backup written by the backup command. Archived records are
+matched to stored ones by title; -policy decides what happens to a match:
+
+  replace   overwrite the stored record with the archived one
+  merge     keep the larger episode counts and the more advanced status
+  skip      keep the stored record
+
+Archived records without a match are created, and stored records missing
+from the backup are left alone.
+
+flags:`
+
+func main() {
+	policyFlag := flag.String("policy", string(backup.Skip), "what to do with records already stored: replace, merge or skip")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	policy, err := backup.ParsePolicy(*policyFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open backup: %v", err)
+	}
+	archive, err := backup.Read(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to read backup: %v", err)
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read schema version: %v", err)
+	}
+	if archive.SchemaVersion > version {
+		log.Fatalf("Backup was taken at schema version %d, newer than this build's %d; restore it with a newer build",
+			archive.SchemaVersion, version)
+	}
+
+	result, err := backup.Restore(ctx,
+		repository.NewRecordRepository(database, repository.WithDuplicateTitles(cfg.DuplicateTitles)), archive, policy)
+	if err != nil {
+		log.Fatalf("Failed to restore backup: %v", err)
+	}
+
+	fmt.Printf("Read %d records and %d in the trash from %s (%s, schema version %d, taken %s)\n",
+		len(archive.Records), len(archive.Trash), flag.Arg(0), archive.Driver, archive.SchemaVersion,
+		archive.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Created %d, updated %d, trashed %d, unchanged %d, failed %d\n",
+		result.Created, result.Updated, result.Trashed, result.Unchanged, len(result.Failed))
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "Failed to restore %v\n", failure)
+	}
+	if len(result.Failed) > 0 {
+		os.Exit(1)
+	}
+}
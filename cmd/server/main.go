@@ -1,37 +1,60 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"golang-watchlist/internal/config"
 	"golang-watchlist/internal/db"
-	"golang-watchlist/internal/initializers"
+	"golang-watchlist/internal/repository"
 	"golang-watchlist/internal/routes"
+	"golang-watchlist/internal/rules"
+	"golang-watchlist/internal/trash"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
-func init() {
-	initializers.LoadEnvVariables()
-}
-
 func main() {
-	database, err := db.NewDB()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
-	defer database.Close()
+
+	// Validated by config.Load.
+	engine, _ := rules.ParseEngine(cfg.StatusRules)
+	opts := []repository.Option{repository.WithDuplicateTitles(cfg.DuplicateTitles), rules.WithEngine(engine)}
+
+	var repo repository.RecordRepositoryInterface
+	var keys repository.IdempotencyStore
+	if cfg.Database.Driver == config.Memory {
+		repo = repository.NewMemoryRecordRepository(opts...)
+		keys = repository.NewMemoryIdempotencyStore()
+	} else {
+		database, err := db.Connect(cfg.Database)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer database.Close()
+		repo = repository.NewRecordRepository(database, opts...)
+		keys = repository.NewIdempotencyStore(database)
+	}
+	log.Printf("Using %s storage", cfg.Database.Driver)
+
+	purger := &trash.Purger{Store: repo, Retention: cfg.Trash.Retention, Interval: cfg.Trash.PurgeInterval}
+	go purger.Run(context.Background())
 
 	router := mux.NewRouter()
 
-	routes.HandleRecordRoutes(router, database)
+	routes.RegisterRecordRoutes(router, repo,
+		routes.WithIdempotency(keys, cfg.IdempotencyTTL),
+		routes.WithIdempotencyLease(cfg.IdempotencyLease),
+		routes.WithDuplicatePolicy(cfg.DuplicateTitles))
 
-	port := os.Getenv("APP_PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Printf("Server starting on port :%v\n", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
+	log.Printf("Server starting on port :%v\n", cfg.Port)
+	if err := http.ListenAndServe(":"+strconv.Itoa(cfg.Port), router); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
@@ -0,0 +1,178 @@
+// Package backup writes the watch list to a self-describing archive and
+// restores it into any repository, whichever database it is backed by.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"io"
+	"time"
+)
+
+const (
+	// Format identifies a backup archive.
+	Format = "golang-watchlist-backup"
+	// FormatVersion is the version of the archive layout this package
+	// writes and the newest one it reads. Version 2 added records in the
+	// trash, which version 1 readers would restore as live records.
+	FormatVersion = 2
+	// Actor is the actor restores are recorded under in the history.
+	Actor = "restore"
+
+	maxLine = 1 << 20
+)
+
+var (
+	// ErrNotBackup is returned for files that are not backup archives.
+	ErrNotBackup = errors.New("file is not a watch list backup")
+	// ErrCorrupt is returned for archives that are truncated or whose
+	// checksum does not match their contents.
+	ErrCorrupt = errors.New("backup is corrupt")
+)
+
+// Header describes an archive. SchemaVersion is the database migration
+// version the records were read from.
+type Header struct {
+	Format        string    `json:"format"`
+	FormatVersion int       `json:"format_version"`
+	SchemaVersion int       `json:"schema_version"`
+	Driver        string    `json:"driver"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// trailer ends an archive. Records counts the record lines, trashed ones
+// included, and SHA256 is the checksum of every line before it.
+type trailer struct {
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+// line is one record of an archive. Records in the trash carry the time
+// they were deleted.
+type line struct {
+	models.Record
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Archive is a backup read back into memory. Records are the records
+// outside the trash and Trash the ones in it.
+type Archive struct {
+	Header
+	Records []models.Record
+	Trash   []models.TrashedRecord
+}
+
+// Write streams every record of repo into w as a gzip-compressed archive:
+// the header, one JSON record per line in ID order, and a trailer holding
+// the record count and the SHA-256 of the lines before it. Records in the
+// trash are included with their deleted_at. The records are read through
+// repository.Snapshot, so the archive is consistent even while the list
+// is being written to. It returns the number of records written and how
+// many of them are in the trash.
+func Write(ctx context.Context, w io.Writer, repo repository.RecordRepositoryInterface, header Header) (records, trashed int, err error) {
+	header.Format = Format
+	header.FormatVersion = FormatVersion
+	if header.CreatedAt.IsZero() {
+		header.CreatedAt = time.Now().UTC()
+	}
+
+	zw := gzip.NewWriter(w)
+	sum := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(zw, sum))
+	if err := enc.Encode(header); err != nil {
+		return 0, 0, err
+	}
+
+	err = repo.Snapshot(ctx, func(record models.TrashedRecord) error {
+		archived := line{Record: record.Record}
+		if !record.DeletedAt.IsZero() {
+			archived.DeletedAt = &record.DeletedAt
+			trashed++
+		}
+		records++
+		return enc.Encode(archived)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end := trailer{Records: records, SHA256: hex.EncodeToString(sum.Sum(nil))}
+	if err := json.NewEncoder(zw).Encode(end); err != nil {
+		return 0, 0, err
+	}
+	return records, trashed, zw.Close()
+}
+
+// Read reads and verifies an archive written by Write. Nothing is returned
+// unless the whole archive is intact.
+func Read(r io.Reader) (*Archive, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, ErrNotBackup
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, maxLine)
+	sum := sha256.New()
+
+	if !scanner.Scan() {
+		return nil, ErrNotBackup
+	}
+	var archive Archive
+	if err := json.Unmarshal(scanner.Bytes(), &archive.Header); err != nil || archive.Format != Format {
+		return nil, ErrNotBackup
+	}
+	if archive.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("backup format version %d is newer than the supported version %d", archive.FormatVersion, FormatVersion)
+	}
+	sum.Write(scanner.Bytes())
+	sum.Write([]byte("\n"))
+
+	// The trailer is the last line, so each line is only hashed and
+	// decoded as a record once the next one shows it is not the last.
+	var last []byte
+	count := 0
+	for scanner.Scan() {
+		if last != nil {
+			count++
+			var archived line
+			if err := json.Unmarshal(last, &archived); err != nil {
+				return nil, fmt.Errorf("%w: record %d: %v", ErrCorrupt, count, err)
+			}
+			if archived.DeletedAt != nil {
+				archive.Trash = append(archive.Trash, models.TrashedRecord{Record: archived.Record, DeletedAt: *archived.DeletedAt})
+			} else {
+				archive.Records = append(archive.Records, archived.Record)
+			}
+			sum.Write(last)
+			sum.Write([]byte("\n"))
+		}
+		last = append(last[:0], scanner.Bytes()...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	var end trailer
+	if last == nil || json.Unmarshal(last, &end) != nil || end.SHA256 == "" {
+		return nil, fmt.Errorf("%w: missing trailer", ErrCorrupt)
+	}
+	if end.Records != count {
+		return nil, fmt.Errorf("%w: trailer counts %d records, found %d", ErrCorrupt, end.Records, count)
+	}
+	want, err := hex.DecodeString(end.SHA256)
+	if err != nil || !bytes.Equal(want, sum.Sum(nil)) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	return &archive, nil
+}
@@ -0,0 +1,237 @@
+package backup
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"slices"
+	"strings"
+)
+
+// Policy decides what a restore does with an archived record that has the
+// same title key as a record already in the repository. Archived records
+// without a match are always created, and stored records missing from the
+// archive are left alone.
+type Policy string
+
+const (
+	// Replace overwrites the stored record with the archived one.
+	Replace Policy = "replace"
+	// Merge combines the two as models.Record.Merge does, keeping the
+	// larger episode counts and the more advanced status.
+	Merge Policy = "merge"
+	// Skip keeps the stored record.
+	Skip Policy = "skip"
+)
+
+func ParsePolicy(value string) (Policy, error) {
+	switch policy := Policy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case Replace, Merge, Skip:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown restore policy %q: want replace, merge or skip", value)
+}
+
+// Result reports what a restore did. Trashed counts records restored into
+// the trash. Unchanged counts matched records the policy left as they
+// were; Failed lists the archived records that could not be written.
+type Result struct {
+	Created   int
+	Updated   int
+	Trashed   int
+	Unchanged int
+	Failed    []Failure
+}
+
+// Failure is an archived record that could not be restored.
+type Failure struct {
+	Record models.Record
+	Err    error
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%q (ID %d in the backup): %v", f.Record.Title, f.Record.ID, f.Err)
+}
+
+// Restore writes the archived records to repo under policy. Records are
+// matched by normalized title rather than ID, since IDs are only
+// meaningful in the database the backup was taken from; records sharing a
+// title are paired up in ID order. Each record is written on its own, so
+// one failure does not stop the others.
+//
+// Archived records in the trash are created and moved to the trash again,
+// unless a stored record, in the trash or not, already has the title;
+// those count as unchanged whatever the policy. They are deleted at the
+// time of the restore, so their trash retention starts over.
+func Restore(ctx context.Context, repo repository.RecordRepositoryInterface, archive *Archive, policy Policy) (*Result, error) {
+	ctx = repository.WithActor(ctx, Actor)
+	existing, err := repo.GetRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(existing, func(a, b models.Record) int { return cmp.Compare(a.ID, b.ID) })
+	byKey := map[string][]models.Record{}
+	for _, record := range existing {
+		key := duplicates.Key(record.Title)
+		byKey[key] = append(byKey[key], record)
+	}
+
+	archived := slices.Clone(archive.Records)
+	slices.SortFunc(archived, func(a, b models.Record) int { return cmp.Compare(a.ID, b.ID) })
+
+	result := &Result{}
+	var ops []repository.BatchOperation
+	var sources []models.Record
+	for _, source := range archived {
+		record := source
+		record.ID, record.Version = 0, 0
+		record.Normalize()
+		if err := record.Validate(); err != nil {
+			result.Failed = append(result.Failed, Failure{Record: source, Err: err})
+			continue
+		}
+
+		key := duplicates.Key(record.Title)
+		matches := byKey[key]
+		if len(matches) == 0 {
+			ops = append(ops, repository.BatchOperation{Op: repository.BatchCreate, Record: record})
+			sources = append(sources, source)
+			continue
+		}
+		stored := matches[0]
+		byKey[key] = matches[1:]
+
+		switch policy {
+		case Replace:
+			record.ID = stored.ID
+		case Merge:
+			record = stored.Merge(record)
+		default:
+			record = stored
+		}
+		if sameFields(record, stored) {
+			result.Unchanged++
+			continue
+		}
+		ops = append(ops, repository.BatchOperation{Op: repository.BatchUpdate, ID: stored.ID, Version: stored.Version, Record: record})
+		sources = append(sources, source)
+	}
+
+	results, err := applyBatches(ctx, repo, ops)
+	if err != nil {
+		return nil, err
+	}
+	for i, batchResult := range results {
+		switch {
+		case batchResult.Err == nil && ops[i].Op == repository.BatchCreate:
+			result.Created++
+		case batchResult.Err == nil:
+			result.Updated++
+		default:
+			result.Failed = append(result.Failed, Failure{Record: sources[i], Err: batchResult.Err})
+		}
+	}
+
+	if err := restoreTrash(ctx, repo, archive.Trash, existing, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// restoreTrash creates the archived records in the trash whose title no
+// stored record has, then moves them to the trash.
+func restoreTrash(ctx context.Context, repo repository.RecordRepositoryInterface, archived []models.TrashedRecord, existing []models.Record, result *Result) error {
+	if len(archived) == 0 {
+		return nil
+	}
+	taken := map[string]bool{}
+	for _, record := range existing {
+		taken[duplicates.Key(record.Title)] = true
+	}
+	trash, err := repo.ListTrash(ctx)
+	if err != nil {
+		return err
+	}
+	for _, record := range trash {
+		taken[duplicates.Key(record.Title)] = true
+	}
+
+	archived = slices.Clone(archived)
+	slices.SortFunc(archived, func(a, b models.TrashedRecord) int { return cmp.Compare(a.ID, b.ID) })
+
+	var creates []repository.BatchOperation
+	var sources []models.Record
+	for _, source := range archived {
+		record := source.Record
+		record.ID, record.Version = 0, 0
+		record.Normalize()
+		if err := record.Validate(); err != nil {
+			result.Failed = append(result.Failed, Failure{Record: source.Record, Err: err})
+			continue
+		}
+		if taken[duplicates.Key(record.Title)] {
+			result.Unchanged++
+			continue
+		}
+		creates = append(creates, repository.BatchOperation{Op: repository.BatchCreate, Record: record})
+		sources = append(sources, source.Record)
+	}
+
+	created, err := applyBatches(ctx, repo, creates)
+	if err != nil {
+		return err
+	}
+	var deletes []repository.BatchOperation
+	var deleted []models.Record
+	for i, batchResult := range created {
+		if batchResult.Err != nil {
+			result.Failed = append(result.Failed, Failure{Record: sources[i], Err: batchResult.Err})
+			continue
+		}
+		deletes = append(deletes, repository.BatchOperation{Op: repository.BatchDelete, ID: batchResult.Record.ID})
+		deleted = append(deleted, sources[i])
+	}
+
+	results, err := applyBatches(ctx, repo, deletes)
+	if err != nil {
+		return err
+	}
+	for i, batchResult := range results {
+		if batchResult.Err != nil {
+			result.Failed = append(result.Failed, Failure{Record: deleted[i], Err: batchResult.Err})
+			continue
+		}
+		result.Trashed++
+	}
+	return nil
+}
+
+// applyBatches applies ops in batches of up to repository.MaxBatchSize
+// and returns the result of each. An unavailable database fails the
+// whole call.
+func applyBatches(ctx context.Context, repo repository.RecordRepositoryInterface, ops []repository.BatchOperation) ([]repository.BatchResult, error) {
+	var results []repository.BatchResult
+	for start := 0; start < len(ops); start += repository.MaxBatchSize {
+		end := min(start+repository.MaxBatchSize, len(ops))
+		batch, err := repo.ApplyBatch(ctx, ops[start:end], false)
+		if err != nil {
+			return nil, err
+		}
+		for _, batchResult := range batch {
+			if errors.Is(batchResult.Err, repository.ErrUnavailable) {
+				return nil, batchResult.Err
+			}
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
+
+func sameFields(a, b models.Record) bool {
+	return a.Title == b.Title && a.TotalEpisodes == b.TotalEpisodes &&
+		a.WatchedEpisodes == b.WatchedEpisodes && a.Type == b.Type && a.Status == b.Status
+}
@@ -0,0 +1,407 @@
+// Package config assembles the application settings. Each source
+// overrides the ones before it:
+//
+//  1. built-in defaults
+//  2. a .env file in the working directory, if there is one
+//  3. a YAML or TOML config file named by -config or CONFIG_FILE
+//  4. the environment
+//  5. command-line flags
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/rules"
+	"golang-watchlist/internal/trash"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Memory is the storage that keeps records in process memory instead of a
+// database, for demos and frontend development.
+const Memory = "memory"
+
+// dotEnvFile is read for settings missing from the environment, as during
+// local development. Its values count as environment variables, so they
+// override the config file.
+const dotEnvFile = ".env"
+
+type Config struct {
+	Port             int
+	Database         db.Config
+	Trash            Trash
+	IdempotencyTTL   time.Duration
+	IdempotencyLease time.Duration
+	DuplicateTitles  duplicates.Policy
+	StatusRules      string
+
+	// sources records where each setting, by key, was last set from.
+	sources map[string]string
+}
+
+type Trash struct {
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+// Default returns the configuration used where no source sets a value.
+func Default() *Config {
+	return &Config{
+		Port: 8080,
+		Database: db.Config{
+			Driver:     db.MySQL,
+			SQLitePath: db.DefaultSQLitePath,
+			MySQL:      db.MySQLConfig{Host: "127.0.0.1", Port: 3306},
+		},
+		Trash:            Trash{Retention: trash.DefaultRetention, PurgeInterval: trash.DefaultInterval},
+		IdempotencyTTL:   repository.DefaultIdempotencyTTL,
+		IdempotencyLease: repository.DefaultIdempotencyLease,
+		DuplicateTitles:  duplicates.Warn,
+		StatusRules:      rules.DefaultSpec,
+		sources:          map[string]string{},
+	}
+}
+
+// setting is one configurable value: its key in a config file, the
+// environment variable and flag that set it, and the field it is stored
+// in. redact hides secrets when the configuration is printed.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	field  func(c *Config) any
+	redact func(value string) string
+}
+
+var settings = []setting{
+	{key: "port", env: "APP_PORT", flag: "port", usage: "port to serve HTTP on",
+		field: func(c *Config) any { return &c.Port }},
+	{key: "database.driver", env: "DB_DRIVER", flag: "storage", usage: "where to keep records: mysql, sqlite, postgres or memory",
+		field: func(c *Config) any { return &c.Database.Driver }},
+	{key: "database.sqlite_path", env: "SQLITE_PATH", flag: "sqlite-path", usage: "SQLite database file, or :memory:",
+		field: func(c *Config) any { return &c.Database.SQLitePath }},
+	{key: "database.postgres_url", env: "POSTGRES_URL", flag: "postgres-url", usage: "PostgreSQL connection URL",
+		field: func(c *Config) any { return &c.Database.PostgresURL }, redact: redactURL},
+	{key: "database.mysql.host", env: "MYSQL_HOST", flag: "mysql-host", usage: "MySQL host",
+		field: func(c *Config) any { return &c.Database.MySQL.Host }},
+	{key: "database.mysql.port", env: "MYSQL_PORT", flag: "mysql-port", usage: "MySQL port",
+		field: func(c *Config) any { return &c.Database.MySQL.Port }},
+	{key: "database.mysql.user", env: "MYSQL_USER", flag: "mysql-user", usage: "MySQL user",
+		field: func(c *Config) any { return &c.Database.MySQL.User }},
+	{key: "database.mysql.password", env: "MYSQL_PASSWORD", flag: "mysql-password", usage: "MySQL password",
+		field: func(c *Config) any { return &c.Database.MySQL.Password }, redact: redactSecret},
+	{key: "database.mysql.database", env: "MYSQL_DATABASE", flag: "mysql-database", usage: "MySQL database name",
+		field: func(c *Config) any { return &c.Database.MySQL.Database }},
+	{key: "trash.retention", env: "TRASH_RETENTION", flag: "trash-retention", usage: "how long deleted records stay restorable; 0 keeps them forever",
+		field: func(c *Config) any { return &c.Trash.Retention }},
+	{key: "trash.purge_interval", env: "TRASH_PURGE_INTERVAL", flag: "trash-purge-interval", usage: "how often expired records are purged",
+		field: func(c *Config) any { return &c.Trash.PurgeInterval }},
+	{key: "idempotency_ttl", env: "IDEMPOTENCY_TTL", flag: "idempotency-ttl", usage: "how long an Idempotency-Key is remembered",
+		field: func(c *Config) any { return &c.IdempotencyTTL }},
+	{key: "idempotency_lease", env: "IDEMPOTENCY_LEASE", flag: "idempotency-lease", usage: "how long a request may hold its Idempotency-Key before a retry takes it over",
+		field: func(c *Config) any { return &c.IdempotencyLease }},
+	{key: "duplicate_titles", env: "DUPLICATE_TITLES", flag: "duplicate-titles", usage: "what to do with duplicate titles: allow, warn or reject",
+		field: func(c *Config) any { return &c.DuplicateTitles }},
+	{key: "status_rules", env: "STATUS_RULES", flag: "status-rules", usage: "comma-separated automatic status rules, or none",
+		field: func(c *Config) any { return &c.StatusRules }},
+}
+
+func (s setting) set(c *Config, value string) error {
+	value = strings.TrimSpace(value)
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("want an integer")
+		}
+		*field = n
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("want a Go duration such as 24h")
+		}
+		*field = d
+	case *duplicates.Policy:
+		policy, err := duplicates.ParsePolicy(value)
+		if err != nil {
+			return errors.New("want allow, warn or reject")
+		}
+		*field = policy
+	}
+	return nil
+}
+
+func (s setting) get(c *Config) string {
+	switch field := s.field(c).(type) {
+	case *string:
+		return *field
+	case *int:
+		return strconv.Itoa(*field)
+	case *time.Duration:
+		return field.String()
+	case *duplicates.Policy:
+		return string(*field)
+	}
+	return ""
+}
+
+// Load registers a flag for every setting on flags, parses args and
+// builds the configuration from all sources, each overriding the one
+// before: defaults, the config file, .env, the environment and flags.
+// Unless the flags fail to
+// parse, the configuration is returned even when it is invalid, so that it
+// can still be printed next to the errors.
+func Load(flags *flag.FlagSet, args []string) (*Config, error) {
+	configFile := flags.String("config", "", "YAML or TOML config file (env CONFIG_FILE)")
+	defaults := Default()
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s", s.usage, s.env)
+		if s.redact == nil {
+			usage += fmt.Sprintf(", default %q", s.get(defaults))
+		}
+		flags.Var(&flagValue{}, s.flag, usage+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	var errs []error
+	apply := func(s setting, value, source, name string) {
+		if err := s.set(cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %v", name, value, err))
+			return
+		}
+		cfg.sources[s.key] = source
+	}
+
+	dotEnv, err := godotenv.Read(dotEnvFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("read %s: %v", dotEnvFile, err))
+	}
+
+	path := *configFile
+	if path == "" {
+		path = lookupEnv(dotEnv, "CONFIG_FILE")
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, s := range settings {
+			if value, ok := values[s.key]; ok {
+				apply(s, value, path, s.key+" in "+path)
+				delete(values, s.key)
+			}
+		}
+		for key := range values {
+			errs = append(errs, fmt.Errorf("unknown setting %q in %s", key, path))
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			apply(s, value, s.env, s.env)
+		} else if value, ok := dotEnv[s.env]; ok {
+			apply(s, value, dotEnvFile, s.env+" in "+dotEnvFile)
+		}
+	}
+
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range settings {
+		if set[s.flag] {
+			apply(s, flags.Lookup(s.flag).Value.String(), "-"+s.flag, "-"+s.flag)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+func lookupEnv(dotEnv map[string]string, name string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return dotEnv[name]
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s %s", describe(key), fmt.Sprintf(format, args...)))
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		invalid("port", "must be between 1 and 65535, got %d", c.Port)
+	}
+	switch c.Database.Driver {
+	case db.MySQL:
+		if c.Database.MySQL.Host == "" {
+			invalid("database.mysql.host", "is required for mysql")
+		}
+		if c.Database.MySQL.Port < 1 || c.Database.MySQL.Port > 65535 {
+			invalid("database.mysql.port", "must be between 1 and 65535, got %d", c.Database.MySQL.Port)
+		}
+		if c.Database.MySQL.Database == "" {
+			invalid("database.mysql.database", "is required for mysql")
+		}
+	case db.SQLite:
+		if c.Database.SQLitePath == "" {
+			invalid("database.sqlite_path", "is required for sqlite")
+		}
+	case db.Postgres:
+		if c.Database.PostgresURL == "" {
+			invalid("database.postgres_url", "is required for postgres")
+		} else if _, err := url.Parse(c.Database.PostgresURL); err != nil {
+			invalid("database.postgres_url", "is not a valid URL")
+		}
+	case Memory:
+	default:
+		invalid("database.driver", "must be mysql, sqlite, postgres or memory, got %q", c.Database.Driver)
+	}
+	if c.Trash.Retention < 0 {
+		invalid("trash.retention", "must not be negative")
+	}
+	if c.Trash.PurgeInterval <= 0 {
+		invalid("trash.purge_interval", "must be greater than 0")
+	}
+	if c.IdempotencyTTL <= 0 {
+		invalid("idempotency_ttl", "must be greater than 0")
+	}
+	if c.IdempotencyLease <= 0 {
+		invalid("idempotency_lease", "must be greater than 0")
+	}
+	if _, err := rules.ParseEngine(c.StatusRules); err != nil {
+		invalid("status_rules", "is invalid: %v", err)
+	}
+	return errors.Join(errs...)
+}
+
+// describe names a setting by its key and environment variable.
+func describe(key string) string {
+	for _, s := range settings {
+		if s.key == key {
+			return fmt.Sprintf("%s (%s)", s.key, s.env)
+		}
+	}
+	return key
+}
+
+// readFile reads a YAML or TOML config file into setting values by key.
+// Nested tables become dotted keys, and lists are joined with commas.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %v", err)
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		_, err = toml.Decode(string(data), &tree)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %v", path, err)
+	}
+
+	values := map[string]string{}
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]string) {
+	for name, value := range tree {
+		key := prefix + name
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(key+".", value, values)
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+// flagValue holds a setting's flag until Load applies it in order.
+type flagValue struct {
+	value string
+}
+
+func (f *flagValue) String() string { return f.value }
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
+// redacted replaces a password that is redacted but otherwise kept, as
+// url.URL.Redacted does.
+const redacted = "xxxxx"
+
+// keywordPassword matches the password of a keyword/value connection
+// string such as "host=db user=app password=secret", quoted or not.
+var keywordPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s]*)`)
+
+// redactURL hides the password of a connection string but keeps the rest,
+// which is useful to see. Both the URL form, with the password in the
+// userinfo or a password query parameter, and the keyword/value form
+// are understood. A URL that can't be parsed is hidden entirely.
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return keywordPassword.ReplaceAllString(value, "${1}"+redacted)
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return redactSecret(value)
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			raw, _, _ := strings.Cut(param, "=")
+			if name, err := url.QueryUnescape(raw); err != nil || strings.EqualFold(name, "password") {
+				params[i] = raw + "=" + redacted
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.Redacted()
+}
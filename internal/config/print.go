@@ -0,0 +1,57 @@
+package config
+
+import (
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Print writes the configuration as a YAML config file, with secrets
+// redacted and each value commented with the source it came from.
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings {
+		value := s.get(c)
+		if s.redact != nil {
+			value = s.redact(value)
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = "default"
+		}
+
+		parent := root
+		path := strings.Split(s.key, ".")
+		for _, name := range path[:len(path)-1] {
+			parent = child(parent, name)
+		}
+		tag := "!!str"
+		if _, ok := s.field(c).(*int); ok {
+			tag = "!!int"
+		}
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: path[len(path)-1]},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, LineComment: source})
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// child returns the mapping stored under name in parent, adding it first
+// if needed.
+func child(parent *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == name {
+			return parent.Content[i+1]
+		}
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, node)
+	return node
+}
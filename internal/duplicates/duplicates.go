@@ -0,0 +1,146 @@
+// Package duplicates finds anime records that are probably the same show
+// entered more than once.
+package duplicates
+
+import (
+	"cmp"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// DefaultThreshold is the similarity from which two titles are reported
+// as likely duplicates.
+const DefaultThreshold = 0.85
+
+// Policy decides what happens when a new record has the same title key as
+// an existing one.
+type Policy string
+
+const (
+	// Allow creates the record without checking.
+	Allow Policy = "allow"
+	// Warn creates the record and reports the existing ones.
+	Warn Policy = "warn"
+	// Reject refuses to create the record.
+	Reject Policy = "reject"
+)
+
+func ParsePolicy(value string) (Policy, error) {
+	switch policy := Policy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case Allow, Warn, Reject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown duplicate title policy %q: want allow, warn or reject", value)
+}
+
+// Key reduces a title to the form compared for uniqueness: lower case
+// words separated by single spaces, with punctuation treated as a space,
+// so "Blue Lock", "blue lock " and "Blue-Lock!" share a key.
+func Key(title string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// Matching returns the records whose title has the same key as title. A
+// title without letters or digits has an empty key and matches nothing.
+func Matching(records []models.Record, title string) []models.Record {
+	key := Key(title)
+	if key == "" {
+		return nil
+	}
+	var matches []models.Record
+	for _, record := range records {
+		if Key(record.Title) == key {
+			matches = append(matches, record)
+		}
+	}
+	return matches
+}
+
+// Similarity scores two titles from 0 to 1 by the edit distance between
+// their keys; titles with the same key score 1.
+func Similarity(a, b string) float64 {
+	return similarity([]rune(Key(a)), []rune(Key(b)))
+}
+
+func similarity(a, b []rune) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Pair is two records whose titles look alike.
+type Pair struct {
+	Records    [2]models.Record `json:"records"`
+	Similarity float64          `json:"similarity"`
+}
+
+// Find pairs up records whose titles are at least threshold similar, most
+// similar first. Within a pair the lower ID comes first.
+func Find(records []models.Record, threshold float64) []Pair {
+	records = slices.Clone(records)
+	slices.SortFunc(records, func(a, b models.Record) int { return cmp.Compare(a.ID, b.ID) })
+
+	keys := make([][]rune, len(records))
+	for i, record := range records {
+		keys[i] = []rune(Key(record.Title))
+	}
+
+	pairs := []Pair{}
+	for i := range records {
+		for j := i + 1; j < len(records); j++ {
+			// The edit distance is at least the difference in length, so
+			// titles of very different lengths can be skipped cheaply.
+			longest := max(len(keys[i]), len(keys[j]), 1)
+			diff := len(keys[i]) - len(keys[j])
+			if 1-float64(max(diff, -diff))/float64(longest) < threshold {
+				continue
+			}
+			if score := similarity(keys[i], keys[j]); score >= threshold {
+				pairs = append(pairs, Pair{Records: [2]models.Record{records[i], records[j]}, Similarity: math.Round(score*1000) / 1000})
+			}
+		}
+	}
+
+	slices.SortStableFunc(pairs, func(a, b Pair) int { return cmp.Compare(b.Similarity, a.Similarity) })
+	return pairs
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"golang-watchlist/internal/duplicates"
+)
+
+// backfills compute data a migration's SQL cannot, keyed by migration
+// name. Each runs right after the up script of its migration, on the
+// same connection and under the same lock.
+var backfills = map[string]func(ctx context.Context, conn *sql.Conn, bind func(string) string) error{
+	"add_title_key": fillTitleKeys,
+}
+
+// fillTitleKeys stores the duplicates.Key of every existing title.
+// unique_title_key is left empty: it is only claimed by writes made
+// while duplicate titles are rejected.
+func fillTitleKeys(ctx context.Context, conn *sql.Conn, bind func(string) string) error {
+	rows, err := conn.QueryContext(ctx, `SELECT id, title FROM watch_list WHERE title_key IS NULL`)
+	if err != nil {
+		return err
+	}
+	titles := map[int]string{}
+	for rows.Next() {
+		var id int
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return err
+		}
+		titles[id] = title
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, title := range titles {
+		_, err := conn.ExecContext(ctx, bind(`UPDATE watch_list SET title_key = ? WHERE id = ?`), duplicates.Key(title), id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
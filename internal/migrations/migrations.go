@@ -0,0 +1,309 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Dialect holds what differs between databases: the embedded migration
+// files and how to take the advisory lock that serialises migrators.
+// Unlock is told whether the run failed, so a dialect whose lock is a
+// transaction can roll the run back instead of committing half of it.
+type Dialect struct {
+	Name   string
+	Files  fs.FS
+	Lock   func(ctx context.Context, conn *sql.Conn) error
+	Unlock func(ctx context.Context, conn *sql.Conn, failed bool) error
+	// TableExists counts the tables named by its one placeholder, so that
+	// Status can read an unmigrated database without creating anything.
+	TableExists string
+	// Bind rewrites ? placeholders for drivers that use another style.
+	Bind func(query string) string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads NNNN_name.up.sql and NNNN_name.down.sql pairs from files and
+// returns them ordered by version.
+func Load(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := Load(dialect.Files)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load %s migrations: %v", dialect.Name, err)
+	}
+	if dialect.Bind == nil {
+		dialect.Bind = func(query string) string { return query }
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// withLock runs fn on a single connection while holding the dialect's
+// migration lock, so concurrent instances apply each migration once.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.dialect.Lock(ctx, conn); err != nil {
+		return fmt.Errorf("Failed to acquire migration lock: %v", err)
+	}
+	defer func() {
+		if unlockErr := m.dialect.Unlock(context.Background(), conn, err != nil); unlockErr != nil && err == nil {
+			err = fmt.Errorf("Failed to release migration lock: %v", unlockErr)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)
+	`)
+	if err != nil {
+		return fmt.Errorf("Failed to create schema_migrations table: %v", err)
+	}
+
+	return fn(conn)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (m *Migrator) applied(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// Up applies every pending migration in order and returns the ones it ran.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := execScript(ctx, conn, migration.Up); err != nil {
+				return fmt.Errorf("Failed to apply migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			if backfill, ok := backfills[migration.Name]; ok {
+				if err := backfill(ctx, conn, m.dialect.Bind); err != nil {
+					return fmt.Errorf("Failed to backfill migration %d_%s: %v", migration.Version, migration.Name, err)
+				}
+			}
+			_, err := conn.ExecContext(ctx,
+				m.dialect.Bind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+				migration.Version, migration.Name, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("Failed to record migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := execScript(ctx, conn, migration.Down); err != nil {
+				return fmt.Errorf("Failed to revert migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			_, err := conn.ExecContext(ctx,
+				m.dialect.Bind(`DELETE FROM schema_migrations WHERE version = ?`), migration.Version)
+			if err != nil {
+				return fmt.Errorf("Failed to unrecord migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Status reports which migrations are applied. It only reads: it takes
+// no lock and reports nothing applied on a database without a
+// schema_migrations table rather than creating one.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var tables int
+	err := m.db.QueryRowContext(ctx, m.dialect.Bind(m.dialect.TableExists), "schema_migrations").Scan(&tables)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to look up schema_migrations table: %v", err)
+	}
+
+	applied := map[int]time.Time{}
+	if tables > 0 {
+		applied, err = m.applied(ctx, m.db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Version returns the highest applied migration version, or 0.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for _, status := range statuses {
+		if status.Applied {
+			version = status.Version
+		}
+	}
+	return version, nil
+}
+
+// execScript runs each statement of a migration file. Statements end with
+// a semicolon at the end of a line; lines starting with -- are comments.
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitStatements(script string) []string {
+	var statements []string
+	var current []string
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";")
+			statements = append(statements, stmt)
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return statements
+}
+
+func sub(files fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(files, path.Clean(dir))
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+)
+
+//go:embed mysql/*.sql
+var mysqlFiles embed.FS
+
+const mysqlLockName = "watch_list_schema_migrations"
+
+// mysqlLockTimeout is how long, in seconds, a migrator waits for another
+// instance to finish.
+const mysqlLockTimeout = 60
+
+var MySQL = Dialect{
+	Name:  "mysql",
+	Files: sub(mysqlFiles, "mysql"),
+	Lock: func(ctx context.Context, conn *sql.Conn) error {
+		var got sql.NullInt64
+		err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, mysqlLockName, mysqlLockTimeout).Scan(&got)
+		if err != nil {
+			return err
+		}
+		if !got.Valid || got.Int64 != 1 {
+			return errors.New("timed out waiting for another migrator")
+		}
+		return nil
+	},
+	Unlock: func(ctx context.Context, conn *sql.Conn, failed bool) error {
+		_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, mysqlLockName)
+		return err
+	},
+	TableExists: `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`,
+}
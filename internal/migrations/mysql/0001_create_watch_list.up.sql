@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS watch_list (
+	id SERIAL PRIMARY KEY,
+	title TEXT NOT NULL,
+	total_episodes INTEGER,
+	watched_episodes INTEGER,
+	type TEXT,
+	status TEXT NOT NULL
+);
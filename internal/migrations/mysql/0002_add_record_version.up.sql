@@ -0,0 +1,10 @@
+-- Tables created by the pre-migration server may already have the column.
+SET @add_version = IF(
+	(SELECT COUNT(*) FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'watch_list' AND COLUMN_NAME = 'version') = 0,
+	'ALTER TABLE watch_list ADD COLUMN version INTEGER NOT NULL DEFAULT 1',
+	'DO 0'
+);
+PREPARE add_version FROM @add_version;
+EXECUTE add_version;
+DEALLOCATE PREPARE add_version;
@@ -0,0 +1,2 @@
+ALTER TABLE watch_list ADD COLUMN deleted_at DATETIME(6) NULL;
+CREATE INDEX idx_watch_list_deleted_at ON watch_list (deleted_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS watch_list_history (
+	id SERIAL PRIMARY KEY,
+	record_id BIGINT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	before_record TEXT NULL,
+	after_record TEXT NULL,
+	changed_at DATETIME(6) NOT NULL,
+	actor TEXT NOT NULL,
+	request_id TEXT NOT NULL
+);
+CREATE INDEX idx_watch_list_history_record_id ON watch_list_history (record_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	request_hash CHAR(64) NOT NULL,
+	status INTEGER NOT NULL,
+	response_header TEXT NULL,
+	response_body MEDIUMTEXT NULL,
+	created_at DATETIME(6) NOT NULL,
+	expires_at DATETIME(6) NOT NULL
+);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,4 @@
+DROP INDEX idx_watch_list_unique_title_key ON watch_list;
+DROP INDEX idx_watch_list_title_key ON watch_list;
+ALTER TABLE watch_list DROP COLUMN unique_title_key;
+ALTER TABLE watch_list DROP COLUMN title_key;
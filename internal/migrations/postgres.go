@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"golang-watchlist/internal/placeholder"
+)
+
+//go:embed postgres/*.sql
+var postgresFiles embed.FS
+
+// postgresLockKey identifies the session advisory lock held while
+// migrating.
+const postgresLockKey = 7_351_220_418
+
+var Postgres = Dialect{
+	Name:  "postgres",
+	Files: sub(postgresFiles, "postgres"),
+	Lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresLockKey)
+		return err
+	},
+	Unlock: func(ctx context.Context, conn *sql.Conn, failed bool) error {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, postgresLockKey)
+		return err
+	},
+	TableExists: `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`,
+	Bind:        placeholder.Dollar,
+}
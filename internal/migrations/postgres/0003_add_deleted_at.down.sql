@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_watch_list_deleted_at;
+ALTER TABLE watch_list DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,2 @@
+ALTER TABLE watch_list ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_watch_list_deleted_at ON watch_list (deleted_at);
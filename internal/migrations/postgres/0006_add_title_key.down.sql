@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_watch_list_unique_title_key;
+DROP INDEX IF EXISTS idx_watch_list_title_key;
+ALTER TABLE watch_list DROP COLUMN IF EXISTS unique_title_key;
+ALTER TABLE watch_list DROP COLUMN IF EXISTS title_key;
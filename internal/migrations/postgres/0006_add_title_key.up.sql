@@ -0,0 +1,4 @@
+ALTER TABLE watch_list ADD COLUMN IF NOT EXISTS title_key VARCHAR(512);
+ALTER TABLE watch_list ADD COLUMN IF NOT EXISTS unique_title_key VARCHAR(512);
+CREATE INDEX IF NOT EXISTS idx_watch_list_title_key ON watch_list (title_key);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_watch_list_unique_title_key ON watch_list (unique_title_key);
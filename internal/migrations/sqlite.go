@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+)
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLite has no advisory locks; instead the whole run happens inside one
+// BEGIN IMMEDIATE transaction, which holds the database's write lock until
+// the migrator is done. A failed run is rolled back as a whole, so no
+// migration is left half applied.
+var SQLite = Dialect{
+	Name:  "sqlite",
+	Files: sub(sqliteFiles, "sqlite"),
+	Lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`)
+		return err
+	},
+	Unlock: func(ctx context.Context, conn *sql.Conn, failed bool) error {
+		end := `COMMIT`
+		if failed {
+			end = `ROLLBACK`
+		}
+		_, err := conn.ExecContext(ctx, end)
+		return err
+	},
+	TableExists: `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+}
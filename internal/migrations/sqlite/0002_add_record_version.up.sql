@@ -0,0 +1 @@
+ALTER TABLE watch_list ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
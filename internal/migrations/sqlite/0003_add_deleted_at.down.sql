@@ -0,0 +1,2 @@
+DROP INDEX idx_watch_list_deleted_at;
+ALTER TABLE watch_list DROP COLUMN deleted_at;
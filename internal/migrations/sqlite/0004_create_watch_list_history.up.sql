@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS watch_list_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	record_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	before_record TEXT,
+	after_record TEXT,
+	changed_at TIMESTAMP NOT NULL,
+	actor TEXT NOT NULL,
+	request_id TEXT NOT NULL
+);
+CREATE INDEX idx_watch_list_history_record_id ON watch_list_history (record_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	idempotency_key TEXT PRIMARY KEY,
+	request_hash TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	response_header TEXT,
+	response_body TEXT,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,4 @@
+ALTER TABLE watch_list ADD COLUMN title_key TEXT;
+ALTER TABLE watch_list ADD COLUMN unique_title_key TEXT;
+CREATE INDEX idx_watch_list_title_key ON watch_list (title_key);
+CREATE UNIQUE INDEX idx_watch_list_unique_title_key ON watch_list (unique_title_key);
@@ -0,0 +1,28 @@
+package models
+
+// statusRank orders statuses from least to most progress, so merging two
+// records keeps the furthest along.
+var statusRank = map[string]int{
+	StatusPlanning:  0,
+	StatusDropped:   1,
+	StatusOnHold:    2,
+	StatusWatching:  3,
+	StatusCompleted: 4,
+}
+
+// Merge combines other into r, as when the same anime was added twice.
+// The result keeps r's ID, title and type, the larger episode counts and
+// the more advanced status. A known total is raised when other has
+// watched more episodes than it allows.
+func (r Record) Merge(other Record) Record {
+	merged := r
+	merged.TotalEpisodes = max(r.TotalEpisodes, other.TotalEpisodes)
+	merged.WatchedEpisodes = max(r.WatchedEpisodes, other.WatchedEpisodes)
+	if merged.TotalEpisodes > 0 && merged.WatchedEpisodes > merged.TotalEpisodes {
+		merged.TotalEpisodes = merged.WatchedEpisodes
+	}
+	if statusRank[other.Status] > statusRank[r.Status] {
+		merged.Status = other.Status
+	}
+	return merged
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Record struct {
 	ID              int    `json:"id" db:"id"`
 	Title           string `json:"title" db:"title"`
@@ -7,4 +9,70 @@ type Record struct {
 	WatchedEpisodes int    `json:"watched_episodes" db:"watched_episodes"`
 	Type            string `json:"type" db:"type"`
 	Status          string `json:"status" db:"status"`
+	Version         int    `json:"version" db:"version"`
+}
+
+// RecordPatch holds the fields of a partial update. Nil fields are left
+// untouched. A non-zero Version makes the update conditional on the
+// stored version.
+type RecordPatch struct {
+	Title           *string `json:"title,omitempty"`
+	TotalEpisodes   *int    `json:"total_episodes,omitempty"`
+	WatchedEpisodes *int    `json:"watched_episodes,omitempty"`
+	Type            *string `json:"type,omitempty"`
+	Status          *string `json:"status,omitempty"`
+	Version         int     `json:"-"`
+}
+
+func (p RecordPatch) IsEmpty() bool {
+	return p.Title == nil && p.TotalEpisodes == nil &&
+		p.WatchedEpisodes == nil && p.Type == nil && p.Status == nil
+}
+
+// Apply copies the set fields of the patch onto record.
+func (p RecordPatch) Apply(record *Record) {
+	if p.Title != nil {
+		record.Title = *p.Title
+	}
+	if p.TotalEpisodes != nil {
+		record.TotalEpisodes = *p.TotalEpisodes
+	}
+	if p.WatchedEpisodes != nil {
+		record.WatchedEpisodes = *p.WatchedEpisodes
+	}
+	if p.Type != nil {
+		record.Type = *p.Type
+	}
+	if p.Status != nil {
+		record.Status = *p.Status
+	}
+}
+
+// TrashedRecord is a soft-deleted record, kept until it is restored or
+// purged.
+type TrashedRecord struct {
+	Record
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// History actions, one per kind of change a record can go through.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// HistoryEntry is one change to a record. Before is nil for a create and
+// After is nil for a delete or purge.
+type HistoryEntry struct {
+	ID        int       `json:"id"`
+	RecordID  int       `json:"record_id"`
+	Action    string    `json:"action"`
+	Before    *Record   `json:"before"`
+	After     *Record   `json:"after"`
+	ChangedAt time.Time `json:"changed_at"`
+	Actor     string    `json:"actor,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
 }
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const MaxTitleLength = 255
+
+const (
+	StatusWatching  = "watching"
+	StatusCompleted = "completed"
+	StatusOnHold    = "on-hold"
+	StatusDropped   = "dropped"
+	StatusPlanning  = "planning"
+)
+
+const (
+	TypeTV      = "tv"
+	TypeMovie   = "movie"
+	TypeOVA     = "ova"
+	TypeONA     = "ona"
+	TypeSpecial = "special"
+)
+
+var Statuses = []string{StatusWatching, StatusCompleted, StatusOnHold, StatusDropped, StatusPlanning}
+
+var Types = []string{TypeTV, TypeMovie, TypeOVA, TypeONA, TypeSpecial}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every field error found on a record so they
+// can be reported together.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		parts[i] = fe.Field + " " + fe.Message
+	}
+	return "invalid record: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(field, message string) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: message})
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Normalize trims the title and lower-cases status and type so that
+// "TV" and " tv" are stored as "tv".
+func (r *Record) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Type = strings.ToLower(strings.TrimSpace(r.Type))
+	r.Status = strings.ToLower(strings.TrimSpace(r.Status))
+}
+
+// Validate reports every field error on the record as a *ValidationError,
+// or nil if the record is valid. Call Normalize first.
+func (r Record) Validate() error {
+	verr := &ValidationError{}
+	validateTitle(verr, r.Title)
+	validateEnum(verr, "status", r.Status, Statuses)
+	validateEnum(verr, "type", r.Type, Types)
+	validateEpisodes(verr, "total_episodes", r.TotalEpisodes)
+	validateEpisodes(verr, "watched_episodes", r.WatchedEpisodes)
+	if r.TotalEpisodes > 0 && r.WatchedEpisodes > r.TotalEpisodes {
+		verr.add("watched_episodes", "cannot exceed total_episodes")
+	}
+	return verr.orNil()
+}
+
+// Normalize applies Record.Normalize to the fields set on the patch.
+func (p *RecordPatch) Normalize() {
+	if p.Title != nil {
+		title := strings.TrimSpace(*p.Title)
+		p.Title = &title
+	}
+	if p.Type != nil {
+		typ := strings.ToLower(strings.TrimSpace(*p.Type))
+		p.Type = &typ
+	}
+	if p.Status != nil {
+		status := strings.ToLower(strings.TrimSpace(*p.Status))
+		p.Status = &status
+	}
+}
+
+// Validate checks the fields set on the patch in isolation. Rules that
+// span fields, such as watched_episodes against total_episodes, need the
+// stored record and are checked by validating the patched record.
+func (p RecordPatch) Validate() error {
+	verr := &ValidationError{}
+	if p.Title != nil {
+		validateTitle(verr, *p.Title)
+	}
+	if p.Status != nil {
+		validateEnum(verr, "status", *p.Status, Statuses)
+	}
+	if p.Type != nil {
+		validateEnum(verr, "type", *p.Type, Types)
+	}
+	if p.TotalEpisodes != nil {
+		validateEpisodes(verr, "total_episodes", *p.TotalEpisodes)
+	}
+	if p.WatchedEpisodes != nil {
+		validateEpisodes(verr, "watched_episodes", *p.WatchedEpisodes)
+	}
+	if p.TotalEpisodes != nil && p.WatchedEpisodes != nil &&
+		*p.TotalEpisodes > 0 && *p.WatchedEpisodes > *p.TotalEpisodes {
+		verr.add("watched_episodes", "cannot exceed total_episodes")
+	}
+	return verr.orNil()
+}
+
+func validateTitle(verr *ValidationError, title string) {
+	switch {
+	case title == "":
+		verr.add("title", "is required")
+	case utf8.RuneCountInString(title) > MaxTitleLength:
+		verr.add("title", fmt.Sprintf("must be at most %d characters", MaxTitleLength))
+	}
+}
+
+func validateEnum(verr *ValidationError, field, value string, allowed []string) {
+	if value == "" {
+		verr.add(field, "is required")
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	verr.add(field, "must be one of "+strings.Join(allowed, ", "))
+}
+
+func validateEpisodes(verr *ValidationError, field string, n int) {
+	if n < 0 {
+		verr.add(field, "cannot be negative")
+	}
+}
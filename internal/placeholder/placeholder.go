@@ -0,0 +1,29 @@
+// Package placeholder rewrites the ? placeholders queries are written with
+// for drivers that expect another style. The repository and the migrator
+// both write their SQL this way.
+package placeholder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dollar rewrites ? placeholders as $1, $2, ... leaving question marks
+// inside quoted literals alone.
+func Dollar(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"golang-watchlist/internal/models"
+	"slices"
+	"time"
+)
+
+// recordsAsOf reconstructs the watch list at the given moment from the
+// change history, ordered by id. A record's state is the After snapshot
+// of its last change at or before that moment; a record whose first
+// change came later is in its Before state. Records with no history have
+// not changed since history began, so their current state stands.
+func recordsAsOf(history []models.HistoryEntry, current []models.Record, at time.Time) []models.Record {
+	state := map[int]*models.Record{}
+	seen := map[int]bool{}
+	for _, entry := range history {
+		switch {
+		case !entry.ChangedAt.After(at):
+			state[entry.RecordID] = entry.After
+		case !seen[entry.RecordID]:
+			state[entry.RecordID] = entry.Before
+		}
+		seen[entry.RecordID] = true
+	}
+
+	var records []models.Record
+	for _, record := range state {
+		if record != nil {
+			records = append(records, *record)
+		}
+	}
+	for _, record := range current {
+		if !seen[record.ID] {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b models.Record) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return records
+}
+
+// recordsAsOf reconstructs the watch list at the given moment, reading
+// only the history entries that decide each record's state: its last
+// change at or before that moment, or else its first change.
+func (r *RecordRepository) recordsAsOf(ctx context.Context, at time.Time) ([]models.Record, error) {
+	query := `
+	SELECT h.record_id, h.before_record, h.after_record, h.changed_at
+	FROM watch_list_history h
+	WHERE h.id = (
+		SELECT MAX(l.id) FROM watch_list_history l
+		WHERE l.record_id = h.record_id AND l.changed_at <= ?
+	) OR h.id = (
+		SELECT MIN(f.id) FROM watch_list_history f
+		WHERE f.record_id = h.record_id
+	)
+	ORDER BY h.id`
+	rows, err := r.db.QueryContext(ctx, r.dialect.bind(query), at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.HistoryEntry
+	for rows.Next() {
+		var entry models.HistoryEntry
+		var before, after sql.NullString
+		if err := rows.Scan(&entry.RecordID, &before, &after, &entry.ChangedAt); err != nil {
+			return nil, err
+		}
+		if entry.Before, err = unmarshalSnapshot(before); err != nil {
+			return nil, err
+		}
+		if entry.After, err = unmarshalSnapshot(after); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Only records untouched since history began are read from watch_list.
+	query = `SELECT ` + recordColumns + ` FROM watch_list WHERE ` + active + `
+	AND id NOT IN (SELECT record_id FROM watch_list_history) ORDER BY id`
+	rows, err = r.db.QueryContext(ctx, r.dialect.bind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	current, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return recordsAsOf(history, current, at), nil
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-watchlist/internal/models"
+)
+
+// MaxBatchSize is the largest number of operations accepted in one batch.
+const MaxBatchSize = 500
+
+// Batch operation kinds.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchOperation is one write in a batch. Creates and updates use Record;
+// updates and deletes name the record with ID. A non-zero Version makes an
+// update or delete conditional on the stored version, like If-Match.
+type BatchOperation struct {
+	Op      string
+	ID      int
+	Record  models.Record
+	Version int
+}
+
+// BatchResult is the outcome of one operation: the record as written, or
+// Err. Deletes leave Record nil.
+type BatchResult struct {
+	Record *models.Record
+	Err    error
+}
+
+// batchWriter applies single operations of a batch, either each in its own
+// transaction or all within one.
+type batchWriter struct {
+	create func(record *models.Record) error
+	update func(record *models.Record) error
+	trash  func(id, version int) error
+}
+
+func (w batchWriter) apply(op BatchOperation) BatchResult {
+	switch op.Op {
+	case BatchCreate:
+		record := op.Record
+		record.ID, record.Version = 0, 0
+		if err := w.create(&record); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Record: &record}
+	case BatchUpdate:
+		record := op.Record
+		record.ID, record.Version = op.ID, op.Version
+		if err := w.update(&record); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Record: &record}
+	case BatchDelete:
+		return BatchResult{Err: w.trash(op.ID, op.Version)}
+	}
+	return BatchResult{Err: invalid("apply batch", op.ID, fmt.Errorf("unknown operation %q", op.Op))}
+}
+
+// run applies ops in order. When atomic, it stops at the first failure and
+// reports it; the caller must then roll back.
+func (w batchWriter) run(ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = w.apply(op)
+		if atomic && results[i].Err != nil {
+			return abortBatch(results, i), results[i].Err
+		}
+	}
+	return results, nil
+}
+
+// abortBatch marks every operation of a rolled back batch except the one
+// that failed as aborted.
+func abortBatch(results []BatchResult, failed int) []BatchResult {
+	for i := range results {
+		if i != failed {
+			results[i] = BatchResult{Err: &Error{Op: "apply batch", Kind: ErrBatchAborted}}
+		}
+	}
+	return results
+}
+
+// ApplyBatch applies ops in order. An atomic batch runs in one transaction
+// and is rolled back entirely when any operation fails; the error of the
+// failed operation is returned, and every other operation reports
+// ErrBatchAborted. Otherwise each operation commits on its own and only
+// its result carries its error.
+func (r *RecordRepository) ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	if !atomic {
+		w := batchWriter{
+			create: func(record *models.Record) error { return r.CreateRecord(ctx, record) },
+			update: func(record *models.Record) error { return r.UpdateRecord(ctx, record) },
+			trash: func(id, version int) error {
+				if version > 0 {
+					return r.DeleteRecordVersion(ctx, id, version)
+				}
+				return r.DeleteRecord(ctx, id)
+			},
+		}
+		return w.run(ops, false)
+	}
+
+	var results []BatchResult
+	var failed error
+	err := r.withTx(ctx, "apply batch", 0, func(tx *sql.Tx) error {
+		w := batchWriter{
+			create: func(record *models.Record) error { return r.createRecord(ctx, tx, record) },
+			update: func(record *models.Record) error { return r.updateRecord(ctx, tx, record) },
+			trash:  func(id, version int) error { return r.trashRecord(ctx, tx, id, version) },
+		}
+		results, failed = w.run(ops, true)
+		return failed
+	})
+	if failed != nil {
+		return results, failed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-watchlist/internal/placeholder"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+// Dialect holds the SQL that differs between the databases RecordRepository
+// runs on. Queries are written with ? placeholders and MySQL syntax
+// otherwise; each field names the piece a dialect replaces.
+type Dialect struct {
+	Name string
+	// LockRow is appended to a SELECT that reads a row it is about to
+	// update. Empty for databases that lock the whole file on write.
+	LockRow string
+	// Greatest and Least are the multi-argument max and min functions.
+	Greatest string
+	Least    string
+	// Like is the case-insensitive LIKE operator.
+	Like string
+	// ByteOrder is a format wrapping a text column so that it sorts and
+	// compares byte by byte, as strings.Compare does for the in-memory
+	// lists, instead of by the column's collation.
+	ByteOrder string
+	// Returning marks drivers without LastInsertId; inserts append
+	// RETURNING id and read the generated key from the result row.
+	Returning bool
+	// Bind rewrites ? placeholders for drivers that use another style.
+	Bind func(query string) string
+}
+
+var MySQL = Dialect{
+	Name:     "mysql",
+	LockRow:  " FOR UPDATE",
+	Greatest: "GREATEST",
+	Least:    "LEAST",
+	Like:     "LIKE",
+	// CAST rather than COLLATE utf8mb4_bin, which fails on tables
+	// created with another character set.
+	ByteOrder: "CAST(%s AS BINARY)",
+}
+
+// SQLite serialises writers with BEGIN IMMEDIATE (see db.OpenSQLite), so it
+// needs no row locks.
+var SQLite = Dialect{
+	Name:     "sqlite",
+	Greatest: "MAX",
+	Least:    "MIN",
+	Like:     "LIKE",
+	// BINARY is already the default collation; spelled out so a column
+	// declared with another one still sorts the same.
+	ByteOrder: "%s COLLATE BINARY",
+}
+
+var Postgres = Dialect{
+	Name:      "postgres",
+	LockRow:   " FOR UPDATE",
+	Greatest:  "GREATEST",
+	Least:     "LEAST",
+	Like:      "ILIKE",
+	ByteOrder: `%s COLLATE "C"`,
+	Returning: true,
+	Bind:      placeholder.Dollar,
+}
+
+// dialectFor picks the dialect matching the driver db was opened with,
+// defaulting to MySQL.
+func dialectFor(db *sql.DB) Dialect {
+	switch db.Driver().(type) {
+	case *sqlite.Driver:
+		return SQLite
+	case *pq.Driver:
+		return Postgres
+	default:
+		return MySQL
+	}
+}
+
+// sortColumn returns the expression to order and compare field by: text
+// columns in byte order, numeric ones as they are.
+func (d Dialect) sortColumn(field string) string {
+	if SortableFields[field] || d.ByteOrder == "" {
+		return field
+	}
+	return fmt.Sprintf(d.ByteOrder, field)
+}
+
+func (d Dialect) bind(query string) string {
+	if d.Bind == nil {
+		return query
+	}
+	return d.Bind(query)
+}
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insert runs an INSERT on q and returns the generated id.
+func (d Dialect) insert(ctx context.Context, q queryer, query string, args ...any) (int, error) {
+	if d.Returning {
+		var id int
+		err := q.QueryRowContext(ctx, d.bind(query+` RETURNING id`), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := q.ExecContext(ctx, d.bind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
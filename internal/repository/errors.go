@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrConflict       = errors.New("record conflict")
+	ErrValidation     = errors.New("invalid record")
+	ErrUnavailable    = errors.New("storage unavailable")
+
+	// ErrVersionMismatch is a conflict raised when a conditional write
+	// names a version that is no longer current.
+	ErrVersionMismatch = fmt.Errorf("version mismatch: %w", ErrConflict)
+
+	// ErrDuplicateTitle is a conflict raised when duplicate titles are
+	// rejected and another record already has the title. The Err of its
+	// *Error is a *DuplicateTitleError.
+	ErrDuplicateTitle = fmt.Errorf("duplicate title: %w", ErrConflict)
+
+	// ErrBatchAborted is reported for the operations of an atomic batch
+	// that were rolled back because another operation failed.
+	ErrBatchAborted = errors.New("batch aborted")
+)
+
+// Error is returned by repository methods. Kind is one of the sentinel
+// errors above so callers can branch with errors.Is, while Err keeps the
+// underlying driver error for logging.
+type Error struct {
+	Op   string
+	ID   int
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	msg := e.Op
+	if e.ID != 0 {
+		msg = fmt.Sprintf("%s %d", msg, e.ID)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v: %v", msg, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Kind)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// DuplicateTitleError names the title of an ErrDuplicateTitle and the
+// record that already has it, when known.
+type DuplicateTitleError struct {
+	Title      string
+	ExistingID int
+}
+
+func (e *DuplicateTitleError) Error() string {
+	if e.ExistingID == 0 {
+		return fmt.Sprintf("%q is already taken", e.Title)
+	}
+	return fmt.Sprintf("%q is already taken by record %d", e.Title, e.ExistingID)
+}
+
+func notFound(op string, id int) error {
+	return &Error{Op: op, ID: id, Kind: ErrRecordNotFound}
+}
+
+func versionMismatch(op string, id int) error {
+	return &Error{Op: op, ID: id, Kind: ErrVersionMismatch}
+}
+
+func duplicateTitle(op string, id int, title string, existingID int) error {
+	return &Error{Op: op, ID: id, Kind: ErrDuplicateTitle, Err: &DuplicateTitleError{Title: title, ExistingID: existingID}}
+}
+
+func invalid(op string, id int, err error) error {
+	return &Error{Op: op, ID: id, Kind: ErrValidation, Err: err}
+}
+
+// translateError classifies a database/sql or driver error into the
+// repository taxonomy. Errors that don't match a known class are returned
+// unchanged and surface as internal errors.
+func translateError(op string, id int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var repoErr *Error
+	if errors.As(err, &repoErr) {
+		return err
+	}
+
+	var kind error
+	var mysqlErr *mysql.MySQLError
+	var sqliteErr *sqlite.Error
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case 1022, 1062, 1586:
+			kind = ErrConflict
+		case 1048, 1264, 1292, 1366, 1406:
+			kind = ErrValidation
+		case 1040, 1205, 1213:
+			kind = ErrUnavailable
+		}
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			kind = ErrConflict
+		case sqlite3.SQLITE_CONSTRAINT_NOTNULL, sqlite3.SQLITE_CONSTRAINT_CHECK, sqlite3.SQLITE_TOOBIG:
+			kind = ErrValidation
+		}
+		// Busy and locked errors come back with extended codes.
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			kind = ErrUnavailable
+		}
+	case errors.As(err, &pqErr):
+		switch string(pqErr.Code) {
+		case "23505":
+			kind = ErrConflict
+		case "22001", "22003", "22P02", "23502", "23514":
+			kind = ErrValidation
+		case "40001", "40P01", "53300", "55P03", "57P01":
+			kind = ErrUnavailable
+		}
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, mysql.ErrInvalidConn),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr):
+		kind = ErrUnavailable
+	}
+
+	if kind == nil {
+		return err
+	}
+	return &Error{Op: op, ID: id, Kind: kind, Err: err}
+}
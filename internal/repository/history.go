@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"golang-watchlist/internal/models"
+)
+
+type actorKey struct{}
+type requestIDKey struct{}
+
+// WithActor returns a context whose writes are recorded in the history as
+// made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// WithRequestID returns a context whose writes are recorded in the history
+// under the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newHistoryEntry describes a change made under ctx. The snapshots are
+// copied so later changes to before and after do not leak into it.
+func newHistoryEntry(ctx context.Context, action string, id int, before, after *models.Record) models.HistoryEntry {
+	entry := models.HistoryEntry{
+		RecordID:  id,
+		Action:    action,
+		ChangedAt: now(),
+		Actor:     actorFrom(ctx),
+		RequestID: requestIDFrom(ctx),
+	}
+	if before != nil {
+		snapshot := *before
+		entry.Before = &snapshot
+	}
+	if after != nil {
+		snapshot := *after
+		entry.After = &snapshot
+	}
+	return entry
+}
+
+// recordHistory appends a change to watch_list_history inside tx, so the
+// entry is committed or rolled back together with the change itself.
+func (r *RecordRepository) recordHistory(ctx context.Context, tx *sql.Tx, action string, id int, before, after *models.Record) error {
+	entry := newHistoryEntry(ctx, action, id, before, after)
+
+	beforeJSON, err := marshalSnapshot(entry.Before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalSnapshot(entry.After)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO watch_list_history (record_id, action, before_record, after_record, changed_at, actor, request_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, r.dialect.bind(query),
+		entry.RecordID, entry.Action, beforeJSON, afterJSON,
+		entry.ChangedAt, entry.Actor, entry.RequestID)
+	return err
+}
+
+func marshalSnapshot(record *models.Record) (sql.NullString, error) {
+	if record == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalSnapshot(data sql.NullString) (*models.Record, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+	var record models.Record
+	if err := json.Unmarshal([]byte(data.String), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetRecordHistory returns every recorded change to the record, oldest
+// first. History outlives the record, so purged records still have one. A
+// record that exists but predates the history table has an empty history.
+func (r *RecordRepository) GetRecordHistory(ctx context.Context, id int) ([]models.HistoryEntry, error) {
+	query := `SELECT id, record_id, action, before_record, after_record, changed_at, actor, request_id
+	FROM watch_list_history WHERE record_id = ? ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, r.dialect.bind(query), id)
+	if err != nil {
+		return nil, translateError("get history", id, err)
+	}
+	defer rows.Close()
+
+	var entries []models.HistoryEntry
+	for rows.Next() {
+		var entry models.HistoryEntry
+		var before, after sql.NullString
+		err := rows.Scan(&entry.ID, &entry.RecordID, &entry.Action,
+			&before, &after, &entry.ChangedAt, &entry.Actor, &entry.RequestID)
+		if err != nil {
+			return nil, translateError("get history", id, err)
+		}
+		if entry.Before, err = unmarshalSnapshot(before); err != nil {
+			return nil, translateError("get history", id, err)
+		}
+		if entry.After, err = unmarshalSnapshot(after); err != nil {
+			return nil, translateError("get history", id, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError("get history", id, err)
+	}
+
+	if len(entries) == 0 {
+		var exists bool
+		query := `SELECT EXISTS(SELECT 1 FROM watch_list WHERE id = ?)`
+		if err := r.db.QueryRowContext(ctx, r.dialect.bind(query), id).Scan(&exists); err != nil {
+			return nil, translateError("get history", id, err)
+		}
+		if !exists {
+			return nil, notFound("get history", id)
+		}
+	}
+
+	return entries, nil
+}
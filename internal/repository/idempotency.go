@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long an Idempotency-Key is remembered.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyLease is how long a request holds its
+// Idempotency-Key before it is presumed dead and a retry may take the key
+// over.
+const DefaultIdempotencyLease = time.Minute
+
+// IdempotentResponse is what a request made with an Idempotency-Key
+// produced, stored so a retry can be answered with the same response.
+// Status is 0 while the original request is still in flight.
+type IdempotentResponse struct {
+	RequestHash string
+	Status      int
+	Header      map[string]string
+	Body        []byte
+}
+
+// IdempotencyStore remembers Idempotency-Keys until they expire.
+type IdempotencyStore interface {
+	// Reserve claims key for a request with the given hash, leased until
+	// leaseUntil. It returns nil once the key is claimed, or the stored
+	// response when the key is already held. A claim that is neither
+	// completed nor released before its lease runs out, because the
+	// request crashed, is abandoned and the next Reserve takes it over.
+	Reserve(ctx context.Context, key, hash string, leaseUntil time.Time) (*IdempotentResponse, error)
+	// Complete stores the response of the request holding key and keeps
+	// it until expiresAt.
+	Complete(ctx context.Context, key string, status int, header map[string]string, body []byte, expiresAt time.Time) error
+	// Release forgets key, so the request can be retried from scratch.
+	Release(ctx context.Context, key string) error
+}
+
+type SQLIdempotencyStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func NewIdempotencyStore(db *sql.DB) *SQLIdempotencyStore {
+	return &SQLIdempotencyStore{db: db, dialect: dialectFor(db)}
+}
+
+var _ IdempotencyStore = &SQLIdempotencyStore{}
+
+// Reserve also deletes every expired key and abandoned claim, which keeps
+// the table small without a separate cleanup job. While a request is in
+// flight expires_at holds its lease.
+func (s *SQLIdempotencyStore) Reserve(ctx context.Context, key, hash string, leaseUntil time.Time) (*IdempotentResponse, error) {
+	reservedAt := now()
+	purge := `DELETE FROM idempotency_keys WHERE expires_at <= ?`
+	if _, err := s.db.ExecContext(ctx, s.dialect.bind(purge), reservedAt); err != nil {
+		return nil, translateError("reserve idempotency key", 0, err)
+	}
+
+	insert := `
+	INSERT INTO idempotency_keys (idempotency_key, request_hash, status, created_at, expires_at)
+	VALUES (?, ?, 0, ?, ?)`
+	_, err := s.db.ExecContext(ctx, s.dialect.bind(insert), key, hash, reservedAt, leaseUntil.UTC())
+	if err == nil {
+		return nil, nil
+	}
+	if err = translateError("reserve idempotency key", 0, err); !errors.Is(err, ErrConflict) {
+		return nil, err
+	}
+
+	query := `SELECT request_hash, status, response_header, response_body
+	FROM idempotency_keys WHERE idempotency_key = ?`
+	var stored IdempotentResponse
+	var header, body sql.NullString
+	err = s.db.QueryRowContext(ctx, s.dialect.bind(query), key).Scan(&stored.RequestHash, &stored.Status, &header, &body)
+	if err != nil {
+		return nil, translateError("reserve idempotency key", 0, err)
+	}
+	if header.Valid {
+		if err := json.Unmarshal([]byte(header.String), &stored.Header); err != nil {
+			return nil, translateError("reserve idempotency key", 0, err)
+		}
+	}
+	stored.Body = []byte(body.String)
+
+	return &stored, nil
+}
+
+func (s *SQLIdempotencyStore) Complete(ctx context.Context, key string, status int, header map[string]string, body []byte, expiresAt time.Time) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE idempotency_keys SET status = ?, response_header = ?, response_body = ?, expires_at = ?
+	WHERE idempotency_key = ?`
+	_, err = s.db.ExecContext(ctx, s.dialect.bind(query), status, string(encoded), string(body), expiresAt.UTC(), key)
+	return translateError("complete idempotency key", 0, err)
+}
+
+func (s *SQLIdempotencyStore) Release(ctx context.Context, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE idempotency_key = ?`
+	_, err := s.db.ExecContext(ctx, s.dialect.bind(query), key)
+	return translateError("release idempotency key", 0, err)
+}
+
+// MemoryIdempotencyStore keeps Idempotency-Keys in process memory.
+type MemoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]memoryIdempotencyKey
+}
+
+type memoryIdempotencyKey struct {
+	response  IdempotentResponse
+	expiresAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{keys: map[string]memoryIdempotencyKey{}}
+}
+
+var _ IdempotencyStore = &MemoryIdempotencyStore{}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key, hash string, leaseUntil time.Time) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservedAt := now()
+	for k, stored := range s.keys {
+		if !stored.expiresAt.After(reservedAt) {
+			delete(s.keys, k)
+		}
+	}
+
+	if stored, ok := s.keys[key]; ok {
+		response := stored.response
+		return &response, nil
+	}
+	s.keys[key] = memoryIdempotencyKey{response: IdempotentResponse{RequestHash: hash}, expiresAt: leaseUntil}
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, status int, header map[string]string, body []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.keys[key]
+	if !ok {
+		return nil
+	}
+	stored.response.Status = status
+	stored.response.Header = header
+	stored.response.Body = body
+	stored.expiresAt = expiresAt
+	s.keys[key] = stored
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, key)
+	return nil
+}
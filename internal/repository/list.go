@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+var ErrInvalidCursor = fmt.Errorf("invalid cursor: %w", ErrValidation)
+
+// SortableFields maps the JSON name of every models.Record field to whether
+// it is numeric, which decides how cursor values are decoded.
+var SortableFields = map[string]bool{
+	"id":               true,
+	"title":            false,
+	"total_episodes":   true,
+	"watched_episodes": true,
+	"type":             false,
+	"status":           false,
+}
+
+type ListOptions struct {
+	Status        string
+	Type          string
+	TitleContains string
+	SortBy        string
+	Descending    bool
+	Limit         int
+	Cursor        string
+	// AsOf lists the records as they were at that moment, reconstructed
+	// from the change history. The zero value lists the current records.
+	AsOf time.Time
+}
+
+type RecordPage struct {
+	Records    []models.Record
+	NextCursor string
+}
+
+// cursor is the decoded form of RecordPage.NextCursor: the sort key and id
+// of the last row on the page, plus the ordering it was produced under.
+type cursor struct {
+	SortBy     string          `json:"s"`
+	Descending bool            `json:"d"`
+	Value      json.RawMessage `json:"v"`
+	ID         int             `json:"id"`
+}
+
+func (o *ListOptions) normalize() error {
+	if o.SortBy == "" {
+		o.SortBy = "id"
+	}
+	if _, ok := SortableFields[o.SortBy]; !ok {
+		return &Error{Op: "list records", Kind: ErrValidation, Err: fmt.Errorf("unknown sort field %q", o.SortBy)}
+	}
+	if o.Limit <= 0 {
+		o.Limit = DefaultListLimit
+	}
+	if o.Limit > MaxListLimit {
+		o.Limit = MaxListLimit
+	}
+	return nil
+}
+
+// sortValue returns the value of the sortable field of record, an int or a
+// string as SortableFields says.
+func sortValue(field string, record models.Record) any {
+	switch field {
+	case "title":
+		return record.Title
+	case "total_episodes":
+		return record.TotalEpisodes
+	case "watched_episodes":
+		return record.WatchedEpisodes
+	case "type":
+		return record.Type
+	case "status":
+		return record.Status
+	default:
+		return record.ID
+	}
+}
+
+func encodeCursor(opts ListOptions, record models.Record) string {
+	raw, _ := json.Marshal(sortValue(opts.SortBy, record))
+	encoded, _ := json.Marshal(cursor{SortBy: opts.SortBy, Descending: opts.Descending, Value: raw, ID: record.ID})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(opts ListOptions) (any, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	if c.SortBy != opts.SortBy || c.Descending != opts.Descending {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	if SortableFields[c.SortBy] {
+		var n int
+		if err := json.Unmarshal(c.Value, &n); err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		return n, c.ID, nil
+	}
+	var s string
+	if err := json.Unmarshal(c.Value, &s); err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	return s, c.ID, nil
+}
+
+// buildListQuery renders the filtered, keyset-paginated SELECT for opts.
+// Rows are ordered by the sort column with id as a tie-breaker so the
+// cursor position is unique. Text columns are ordered in byte order so
+// every backend pages the same way pageOf does. One extra row is fetched to detect whether
+// another page exists.
+func buildListQuery(d Dialect, opts ListOptions) (string, []any, error) {
+	where := []string{active}
+	var args []any
+
+	if opts.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, opts.Status)
+	}
+	if opts.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, opts.Type)
+	}
+	if opts.TitleContains != "" {
+		where = append(where, `title `+d.Like+` ? ESCAPE '!'`)
+		args = append(args, "%"+escapeLike(opts.TitleContains)+"%")
+	}
+
+	cmp, dir := ">", "ASC"
+	if opts.Descending {
+		cmp, dir = "<", "DESC"
+	}
+
+	if opts.Cursor != "" {
+		value, id, err := decodeCursor(opts)
+		if err != nil {
+			return "", nil, err
+		}
+		if opts.SortBy == "id" {
+			where = append(where, "id "+cmp+" ?")
+			args = append(args, id)
+		} else {
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", d.sortColumn(opts.SortBy), cmp))
+			args = append(args, value, value, id)
+		}
+	}
+
+	query := `SELECT ` + recordColumns + ` FROM watch_list WHERE ` + strings.Join(where, " AND ")
+	if opts.SortBy == "id" {
+		query += fmt.Sprintf(` ORDER BY id %s`, dir)
+	} else {
+		query += fmt.Sprintf(` ORDER BY %[1]s %[2]s, id %[2]s`, d.sortColumn(opts.SortBy), dir)
+	}
+	query += ` LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	return d.bind(query), args, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(s)
+}
+
+func (r *RecordRepository) ListRecords(ctx context.Context, opts ListOptions) (*RecordPage, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+
+	if !opts.AsOf.IsZero() {
+		records, err := r.recordsAsOf(ctx, opts.AsOf)
+		if err != nil {
+			return nil, translateError("list records", 0, err)
+		}
+		return pageOf(records, opts)
+	}
+
+	query, args, err := buildListQuery(r.dialect, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError("list records", 0, err)
+	}
+	defer rows.Close()
+
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, translateError("list records", 0, err)
+	}
+
+	page := &RecordPage{Records: records}
+	if len(records) > opts.Limit {
+		page.Records = records[:opts.Limit]
+		page.NextCursor = encodeCursor(opts, page.Records[opts.Limit-1])
+	}
+	return page, nil
+}
+
+// pageOf filters, sorts and paginates records in memory exactly as
+// buildListQuery does in SQL. opts must be normalized.
+func pageOf(all []models.Record, opts ListOptions) (*RecordPage, error) {
+	var after any
+	var afterID int
+	if opts.Cursor != "" {
+		var err error
+		after, afterID, err = decodeCursor(opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// compare orders records by the sort field with id as a tie-breaker,
+	// matching ORDER BY col, id.
+	compare := func(value any, id int, record models.Record) int {
+		c := compareValues(value, sortValue(opts.SortBy, record))
+		if c == 0 {
+			c = cmp.Compare(id, record.ID)
+		}
+		if opts.Descending {
+			c = -c
+		}
+		return c
+	}
+
+	title := strings.ToLower(opts.TitleContains)
+	var records []models.Record
+	for _, record := range all {
+		if opts.Status != "" && record.Status != opts.Status {
+			continue
+		}
+		if opts.Type != "" && record.Type != opts.Type {
+			continue
+		}
+		if title != "" && !strings.Contains(strings.ToLower(record.Title), title) {
+			continue
+		}
+		if opts.Cursor != "" && compare(after, afterID, record) >= 0 {
+			continue
+		}
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b models.Record) int {
+		return compare(sortValue(opts.SortBy, a), a.ID, b)
+	})
+
+	page := &RecordPage{Records: records}
+	if len(records) > opts.Limit {
+		page.Records = records[:opts.Limit]
+		page.NextCursor = encodeCursor(opts, page.Records[opts.Limit-1])
+	}
+	return page, nil
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
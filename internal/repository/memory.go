@@ -0,0 +1,376 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"golang-watchlist/internal/models"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryRecordRepository keeps records in a map guarded by a mutex. It
+// behaves like RecordRepository, down to the errors, version checks and
+// list ordering, but everything is lost when the process exits.
+type MemoryRecordRepository struct {
+	mu      sync.RWMutex
+	records map[int]models.Record
+	trash   map[int]models.TrashedRecord
+	history []models.HistoryEntry
+	lastID  int
+	options options
+}
+
+func NewMemoryRecordRepository(opts ...Option) *MemoryRecordRepository {
+	return &MemoryRecordRepository{
+		records: map[int]models.Record{},
+		trash:   map[int]models.TrashedRecord{},
+		options: newOptions(opts),
+	}
+}
+
+var _ RecordRepositoryInterface = &MemoryRecordRepository{}
+
+func (r *MemoryRecordRepository) CreateRecord(ctx context.Context, record *models.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createRecord(ctx, record)
+}
+
+// createRecord stores a new record. Callers hold r.mu.
+func (r *MemoryRecordRepository) createRecord(ctx context.Context, record *models.Record) error {
+	record.Normalize()
+	r.options.runBeforeWrite(ctx, record)
+	if err := record.Validate(); err != nil {
+		return invalid("create record", 0, err)
+	}
+	if err := r.claimTitle("create record", 0, record.Title); err != nil {
+		return err
+	}
+
+	// IDs are never reused, like an auto-increment column.
+	r.lastID++
+	record.ID = r.lastID
+	record.Version = 1
+	r.records[record.ID] = *record
+	r.recordHistory(ctx, models.ActionCreate, record.ID, nil, record)
+	return nil
+}
+
+func (r *MemoryRecordRepository) GetRecords(ctx context.Context) ([]models.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sorted(), nil
+}
+
+// sorted returns a copy of every record ordered by id, or nil when there
+// are none. Callers hold r.mu.
+func (r *MemoryRecordRepository) sorted() []models.Record {
+	var records []models.Record
+	for _, record := range r.records {
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b models.Record) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return records
+}
+
+func (r *MemoryRecordRepository) ListRecords(ctx context.Context, opts ListOptions) (*RecordPage, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	all := r.sorted()
+	if !opts.AsOf.IsZero() {
+		all = recordsAsOf(r.history, all, opts.AsOf)
+	}
+	r.mu.RUnlock()
+
+	return pageOf(all, opts)
+}
+
+func (r *MemoryRecordRepository) GetRecordByID(ctx context.Context, id int) (*models.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.records[id]
+	if !ok {
+		return nil, notFound("get record", id)
+	}
+	return &record, nil
+}
+
+// UpdateRecord replaces every field of the record. A non-zero
+// record.Version makes the update conditional on the stored version; on
+// success record.Version holds the new version.
+func (r *MemoryRecordRepository) UpdateRecord(ctx context.Context, record *models.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.updateRecord(ctx, record)
+}
+
+// updateRecord replaces a stored record. Callers hold r.mu.
+func (r *MemoryRecordRepository) updateRecord(ctx context.Context, record *models.Record) error {
+	record.Normalize()
+	r.options.runBeforeWrite(ctx, record)
+	if err := record.Validate(); err != nil {
+		return invalid("update record", record.ID, err)
+	}
+
+	stored, ok := r.records[record.ID]
+	if !ok {
+		return notFound("update record", record.ID)
+	}
+	if record.Version > 0 && record.Version != stored.Version {
+		return versionMismatch("update record", record.ID)
+	}
+	if err := r.retitle("update record", record.ID, stored.Title, record.Title); err != nil {
+		return err
+	}
+
+	record.Version = stored.Version + 1
+	r.records[record.ID] = *record
+	r.recordHistory(ctx, models.ActionUpdate, record.ID, &stored, record)
+	return nil
+}
+
+func (r *MemoryRecordRepository) PatchRecord(ctx context.Context, id int, patch models.RecordPatch) (*models.Record, error) {
+	patch.Normalize()
+	if err := patch.Validate(); err != nil {
+		return nil, invalid("patch record", id, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.records[id]
+	if !ok {
+		return nil, notFound("patch record", id)
+	}
+	if patch.Version > 0 && patch.Version != before.Version {
+		return nil, versionMismatch("patch record", id)
+	}
+
+	record := before
+	patch.Apply(&record)
+	record.Normalize()
+	if !patch.IsEmpty() {
+		r.options.runBeforeWrite(ctx, &record)
+	}
+	if err := record.Validate(); err != nil {
+		return nil, invalid("patch record", id, err)
+	}
+
+	if !patch.IsEmpty() {
+		if err := r.retitle("patch record", id, before.Title, record.Title); err != nil {
+			return nil, err
+		}
+		record.Version++
+		r.records[id] = record
+		r.recordHistory(ctx, models.ActionUpdate, id, &before, &record)
+	}
+	return &record, nil
+}
+
+// IncrementProgress adds delta to watched_episodes, clamped to
+// [0, total_episodes]. A total of 0 means unknown and only the lower bound
+// applies.
+func (r *MemoryRecordRepository) IncrementProgress(ctx context.Context, id int, delta int) (*models.Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.records[id]
+	if !ok {
+		return nil, notFound("increment progress", id)
+	}
+
+	record := before
+	record.WatchedEpisodes = incrementProgress(record, delta)
+	r.options.runBeforeWrite(ctx, &record)
+	if err := record.Validate(); err != nil {
+		return nil, invalid("increment progress", id, err)
+	}
+	record.Version++
+	r.records[id] = record
+	r.recordHistory(ctx, models.ActionUpdate, id, &before, &record)
+	return &record, nil
+}
+
+// DeleteRecord moves the record to the trash.
+func (r *MemoryRecordRepository) DeleteRecord(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.trashRecord(ctx, id, 0)
+}
+
+// DeleteRecordVersion trashes the record only if its stored version
+// matches.
+func (r *MemoryRecordRepository) DeleteRecordVersion(ctx context.Context, id int, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.trashRecord(ctx, id, version)
+}
+
+// trashRecord soft-deletes a record, conditionally on its version when
+// version is non-zero. Callers hold r.mu.
+func (r *MemoryRecordRepository) trashRecord(ctx context.Context, id int, version int) error {
+	record, ok := r.records[id]
+	if !ok {
+		return notFound("delete record", id)
+	}
+	if version > 0 && record.Version != version {
+		return versionMismatch("delete record", id)
+	}
+
+	r.recordHistory(ctx, models.ActionDelete, id, &record, nil)
+	record.Version++
+	delete(r.records, id)
+	r.trash[id] = models.TrashedRecord{Record: record, DeletedAt: now()}
+	return nil
+}
+
+// ListTrash returns the trashed records, most recently deleted first.
+func (r *MemoryRecordRepository) ListTrash(ctx context.Context) ([]models.TrashedRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var records []models.TrashedRecord
+	for _, record := range r.trash {
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b models.TrashedRecord) int {
+		if c := b.DeletedAt.Compare(a.DeletedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return records, nil
+}
+
+// RestoreRecord takes a record out of the trash. Records that are not in
+// the trash are reported as not found.
+func (r *MemoryRecordRepository) RestoreRecord(ctx context.Context, id int) (*models.Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trashed, ok := r.trash[id]
+	if !ok {
+		return nil, notFound("restore record", id)
+	}
+	if err := r.claimTitle("restore record", id, trashed.Title); err != nil {
+		return nil, err
+	}
+	record := trashed.Record
+	record.Version++
+	delete(r.trash, id)
+	r.records[id] = record
+	r.recordHistory(ctx, models.ActionRestore, id, &trashed.Record, &record)
+	return &record, nil
+}
+
+// PurgeTrash permanently deletes records trashed before the given time and
+// returns how many were removed. Their history is kept.
+func (r *MemoryRecordRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []int
+	for id, record := range r.trash {
+		if record.DeletedAt.Before(before) {
+			expired = append(expired, id)
+		}
+	}
+	slices.Sort(expired)
+
+	for _, id := range expired {
+		record := r.trash[id].Record
+		r.recordHistory(ctx, models.ActionPurge, id, &record, nil)
+		delete(r.trash, id)
+	}
+	return len(expired), nil
+}
+
+// recordHistory appends a change to the history. Callers hold r.mu.
+func (r *MemoryRecordRepository) recordHistory(ctx context.Context, action string, id int, before, after *models.Record) {
+	entry := newHistoryEntry(ctx, action, id, before, after)
+	entry.ID = len(r.history) + 1
+	r.history = append(r.history, entry)
+}
+
+// GetRecordHistory returns every recorded change to the record, oldest
+// first. History outlives the record, so purged records still have one.
+func (r *MemoryRecordRepository) GetRecordHistory(ctx context.Context, id int) ([]models.HistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []models.HistoryEntry
+	for _, entry := range r.history {
+		if entry.RecordID == id {
+			entries = append(entries, entry)
+		}
+	}
+	if entries == nil {
+		return nil, notFound("get history", id)
+	}
+	return entries, nil
+}
+
+// ApplyBatch applies ops in order with the same semantics as
+// RecordRepository.ApplyBatch. An atomic batch that fails is undone by
+// restoring the state from before it; like an auto-increment column, the
+// IDs it handed out are not reused.
+func (r *MemoryRecordRepository) ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := maps.Clone(r.records)
+	trash := maps.Clone(r.trash)
+	history := len(r.history)
+
+	w := batchWriter{
+		create: func(record *models.Record) error { return r.createRecord(ctx, record) },
+		update: func(record *models.Record) error { return r.updateRecord(ctx, record) },
+		trash:  func(id, version int) error { return r.trashRecord(ctx, id, version) },
+	}
+	results, err := w.run(ops, atomic)
+	if err != nil {
+		r.records, r.trash, r.history = records, trash, r.history[:history]
+	}
+	return results, err
+}
+
+// MergeRecords merges like RecordRepository.MergeRecords.
+func (r *MemoryRecordRepository) MergeRecords(ctx context.Context, keepID, removeID int) (*models.Record, error) {
+	if keepID == removeID {
+		return nil, invalid("merge records", keepID, errMergeSelf)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keep, ok := r.records[keepID]
+	if !ok {
+		return nil, notFound("merge records", keepID)
+	}
+	remove, ok := r.records[removeID]
+	if !ok {
+		return nil, notFound("merge records", removeID)
+	}
+
+	merged := keep.Merge(remove)
+	if err := r.updateRecord(ctx, &merged); err != nil {
+		return nil, err
+	}
+	if err := r.trashRecord(ctx, removeID, 0); err != nil {
+		return nil, err
+	}
+	return &merged, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"golang-watchlist/internal/models"
+)
+
+var errMergeSelf = errors.New("cannot merge a record into itself")
+
+// MergeRecords folds the record removeID into keepID, as described by
+// models.Record.Merge, and moves removeID to the trash. Both changes
+// commit together and show up in the history of their records.
+func (r *RecordRepository) MergeRecords(ctx context.Context, keepID, removeID int) (*models.Record, error) {
+	if keepID == removeID {
+		return nil, invalid("merge records", keepID, errMergeSelf)
+	}
+
+	var merged models.Record
+	err := r.withTx(ctx, "merge records", keepID, func(tx *sql.Tx) error {
+		// Lock in ID order so that concurrent merges of the same pair
+		// cannot deadlock.
+		locked := map[int]*models.Record{}
+		for _, id := range []int{min(keepID, removeID), max(keepID, removeID)} {
+			record, err := r.lockRecord(ctx, tx, "merge records", id)
+			if err != nil {
+				return err
+			}
+			locked[id] = record
+		}
+
+		merged = locked[keepID].Merge(*locked[removeID])
+		if err := r.updateRecord(ctx, tx, &merged); err != nil {
+			return err
+		}
+		return r.trashRecord(ctx, tx, removeID, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &merged, nil
+}
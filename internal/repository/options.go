@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/models"
+)
+
+// Option configures a repository.
+type Option func(*options)
+
+type options struct {
+	duplicateTitles duplicates.Policy
+	beforeWrite     func(ctx context.Context, record *models.Record)
+}
+
+func newOptions(opts []Option) options {
+	o := options{duplicateTitles: duplicates.Allow}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithBeforeWrite passes the record of every create, update, patch and
+// progress increment to fn just before it is stored. fn runs inside the
+// write's transaction, on the locked row, so the fields it derives are
+// stored atomically with the change and count as one version and one
+// history entry. ctx is the context of the write, which fn may consult to
+// skip it. fn must not change the ID or version.
+func WithBeforeWrite(fn func(ctx context.Context, record *models.Record)) Option {
+	return func(o *options) {
+		o.beforeWrite = fn
+	}
+}
+
+// runBeforeWrite runs the hook set with WithBeforeWrite, if any.
+func (o options) runBeforeWrite(ctx context.Context, record *models.Record) {
+	if o.beforeWrite != nil {
+		o.beforeWrite(ctx, record)
+	}
+}
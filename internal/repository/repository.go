@@ -3,65 +3,140 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"golang-watchlist/internal/models"
+	"strings"
+	"time"
 )
 
 type RecordRepositoryInterface interface {
 	CreateRecord(ctx context.Context, record *models.Record) error
 	GetRecords(ctx context.Context) ([]models.Record, error)
+	ListRecords(ctx context.Context, opts ListOptions) (*RecordPage, error)
+	GetRecordByID(ctx context.Context, id int) (*models.Record, error)
 	UpdateRecord(ctx context.Context, record *models.Record) error
+	PatchRecord(ctx context.Context, id int, patch models.RecordPatch) (*models.Record, error)
+	IncrementProgress(ctx context.Context, id int, delta int) (*models.Record, error)
 	DeleteRecord(ctx context.Context, id int) error
+	DeleteRecordVersion(ctx context.Context, id int, version int) error
+	ListTrash(ctx context.Context) ([]models.TrashedRecord, error)
+	RestoreRecord(ctx context.Context, id int) (*models.Record, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+	GetRecordHistory(ctx context.Context, id int) ([]models.HistoryEntry, error)
+	ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error)
+	MergeRecords(ctx context.Context, keepID, removeID int) (*models.Record, error)
+	FindByTitle(ctx context.Context, title string) ([]models.Record, error)
+	Snapshot(ctx context.Context, fn func(models.TrashedRecord) error) error
+}
+
+const recordColumns = `id, title, total_episodes, watched_episodes, type, status, version`
+
+// active restricts a query to records that are not in the trash.
+const active = `deleted_at IS NULL`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecord(row rowScanner, record *models.Record) error {
+	return row.Scan(&record.ID,
+		&record.Title, &record.TotalEpisodes,
+		&record.WatchedEpisodes, &record.Type,
+		&record.Status, &record.Version)
 }
 
 type RecordRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
+	options options
 }
 
-func NewRecordRepository(db *sql.DB) *RecordRepository {
-	return &RecordRepository{db: db}
+// NewRecordRepository returns a repository for db, speaking the SQL
+// dialect of the driver it was opened with.
+func NewRecordRepository(db *sql.DB, opts ...Option) *RecordRepository {
+	return &RecordRepository{db: db, dialect: dialectFor(db), options: newOptions(opts)}
 }
 
 var _ RecordRepositoryInterface = &RecordRepository{}
 
 func (r *RecordRepository) CreateRecord(ctx context.Context, record *models.Record) error {
-	query := `
-	INSERT INTO watch_list (title, total_episodes, watched_episodes, type, status)
-	VALUES (?, ?, ?, ?, ?)
-	`
-	result, err := r.db.ExecContext(ctx, query,
-		record.Title, record.TotalEpisodes,
-		record.WatchedEpisodes, record.Type,
-		record.Status)
+	return r.withTx(ctx, "create record", 0, func(tx *sql.Tx) error {
+		return r.createRecord(ctx, tx, record)
+	})
+}
+
+// withTx runs fn in a transaction, committing it when fn succeeds.
+func (r *RecordRepository) withTx(ctx context.Context, op string, id int, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
+		return translateError(op, id, err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
 		return err
 	}
 
-	id, err := result.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		return translateError(op, id, err)
+	}
+
+	return nil
+}
+
+func (r *RecordRepository) createRecord(ctx context.Context, tx *sql.Tx, record *models.Record) error {
+	record.Normalize()
+	r.options.runBeforeWrite(ctx, record)
+	if err := record.Validate(); err != nil {
+		return invalid("create record", 0, err)
+	}
+
+	key, unique, err := r.claimTitle(ctx, tx, "create record", 0, record.Title)
 	if err != nil {
 		return err
 	}
 
-	record.ID = int(id)
+	query := `
+	INSERT INTO watch_list (title, title_key, unique_title_key, total_episodes, watched_episodes, type, status)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	id, err := r.dialect.insert(ctx, tx, query,
+		record.Title, key, unique,
+		record.TotalEpisodes, record.WatchedEpisodes,
+		record.Type, record.Status)
+	if err != nil {
+		return titleConflict("create record", 0, record.Title, err)
+	}
+
+	record.ID = id
+	record.Version = 1
+	if err := r.recordHistory(ctx, tx, models.ActionCreate, id, nil, record); err != nil {
+		return translateError("create record", id, err)
+	}
+
 	return nil
 }
 
 func (r *RecordRepository) GetRecords(ctx context.Context) ([]models.Record, error) {
-	query := `SELECT id, title, total_episodes, watched_episodes, type, status FROM watch_list`
-	rows, err := r.db.QueryContext(ctx, query)
+	query := `SELECT ` + recordColumns + ` FROM watch_list WHERE ` + active
+	rows, err := r.db.QueryContext(ctx, r.dialect.bind(query))
 	if err != nil {
-		return nil, err
+		return nil, translateError("get records", 0, err)
 	}
 	defer rows.Close()
 
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, translateError("get records", 0, err)
+	}
+
+	return records, nil
+}
+
+func scanRecords(rows *sql.Rows) ([]models.Record, error) {
 	var records []models.Record
 	for rows.Next() {
 		var record models.Record
-		err := rows.Scan(&record.ID,
-			&record.Title, &record.TotalEpisodes,
-			&record.WatchedEpisodes, &record.Type,
-			&record.Status)
-		if err != nil {
+		if err := scanRecord(rows, &record); err != nil {
 			return nil, err
 		}
 		records = append(records, record)
@@ -74,40 +149,397 @@ func (r *RecordRepository) GetRecords(ctx context.Context) ([]models.Record, err
 	return records, nil
 }
 
+func (r *RecordRepository) GetRecordByID(ctx context.Context, id int) (*models.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM watch_list WHERE id = ? AND ` + active
+
+	var record models.Record
+	err := scanRecord(r.db.QueryRowContext(ctx, r.dialect.bind(query), id), &record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("get record", id)
+	}
+	if err != nil {
+		return nil, translateError("get record", id, err)
+	}
+
+	return &record, nil
+}
+
+// lockRecord reads a record that is not in the trash inside tx, locking
+// its row where the dialect supports it.
+func (r *RecordRepository) lockRecord(ctx context.Context, tx *sql.Tx, op string, id int) (*models.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM watch_list WHERE id = ? AND ` + active + r.dialect.LockRow
+
+	var record models.Record
+	err := scanRecord(tx.QueryRowContext(ctx, r.dialect.bind(query), id), &record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound(op, id)
+	}
+	if err != nil {
+		return nil, translateError(op, id, err)
+	}
+
+	return &record, nil
+}
+
+// UpdateRecord replaces every field of the record. A non-zero
+// record.Version makes the update conditional on the stored version; on
+// success record.Version holds the new version.
 func (r *RecordRepository) UpdateRecord(ctx context.Context, record *models.Record) error {
-	query := `
-	UPDATE watch_list
-	SET title = ?, total_episodes = ?, watched_episodes = ?,
-	type = ?, status = ? WHERE id = ?
-	`
+	return r.withTx(ctx, "update record", record.ID, func(tx *sql.Tx) error {
+		return r.updateRecord(ctx, tx, record)
+	})
+}
+
+func (r *RecordRepository) updateRecord(ctx context.Context, tx *sql.Tx, record *models.Record) error {
+	record.Normalize()
+	r.options.runBeforeWrite(ctx, record)
+	if err := record.Validate(); err != nil {
+		return invalid("update record", record.ID, err)
+	}
+
+	before, err := r.lockRecord(ctx, tx, "update record", record.ID)
+	if err != nil {
+		return err
+	}
+	if record.Version > 0 && record.Version != before.Version {
+		return versionMismatch("update record", record.ID)
+	}
 
-	result, err := r.db.ExecContext(ctx, query,
-		record.Title, record.TotalEpisodes,
-		record.WatchedEpisodes, record.Type,
-		record.Status, record.ID)
-	rowsAffected, err := result.RowsAffected()
+	columns := []string{"title = ?", "total_episodes = ?", "watched_episodes = ?", "type = ?", "status = ?"}
+	args := []any{record.Title, record.TotalEpisodes, record.WatchedEpisodes, record.Type, record.Status}
+	titleColumns, titleArgs, err := r.titleColumns(ctx, tx, "update record", record.ID, before.Title, record.Title)
 	if err != nil {
 		return err
 	}
+	columns = append(append(columns, titleColumns...), "version = version + 1")
+	args = append(append(args, titleArgs...), record.ID)
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no record found with ID %d", record.ID)
+	query := `UPDATE watch_list SET ` + strings.Join(columns, ", ") + ` WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, r.dialect.bind(query), args...); err != nil {
+		return titleConflict("update record", record.ID, record.Title, err)
+	}
+
+	record.Version = before.Version + 1
+	if err := r.recordHistory(ctx, tx, models.ActionUpdate, record.ID, before, record); err != nil {
+		return translateError("update record", record.ID, err)
 	}
 
 	return nil
 }
 
+func (r *RecordRepository) PatchRecord(ctx context.Context, id int, patch models.RecordPatch) (*models.Record, error) {
+	patch.Normalize()
+	if err := patch.Validate(); err != nil {
+		return nil, invalid("patch record", id, err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError("patch record", id, err)
+	}
+	defer tx.Rollback()
+
+	before, err := r.lockRecord(ctx, tx, "patch record", id)
+	if err != nil {
+		return nil, err
+	}
+	if patch.Version > 0 && patch.Version != before.Version {
+		return nil, versionMismatch("patch record", id)
+	}
+
+	record := *before
+	patch.Apply(&record)
+	record.Normalize()
+	if !patch.IsEmpty() {
+		r.options.runBeforeWrite(ctx, &record)
+	}
+	if err := record.Validate(); err != nil {
+		return nil, invalid("patch record", id, err)
+	}
+
+	if !patch.IsEmpty() {
+		columns, args := changedColumns(before, &record)
+		titleColumns, titleArgs, err := r.titleColumns(ctx, tx, "patch record", id, before.Title, record.Title)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(append(columns, titleColumns...), "version = version + 1")
+		args = append(append(args, titleArgs...), id)
+
+		update := `UPDATE watch_list SET ` + strings.Join(columns, ", ") + ` WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, r.dialect.bind(update), args...); err != nil {
+			return nil, titleConflict("patch record", id, record.Title, err)
+		}
+		record.Version++
+
+		if err := r.recordHistory(ctx, tx, models.ActionUpdate, id, before, &record); err != nil {
+			return nil, translateError("patch record", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, translateError("patch record", id, err)
+	}
+
+	return &record, nil
+}
+
+// IncrementProgress adds delta to watched_episodes, clamped to
+// [0, total_episodes]. A total of 0 means unknown and only the lower bound
+// applies. The clamped increment, any fields derived by a WithBeforeWrite
+// hook and the version bump are stored by one UPDATE, returning the row
+// where the dialect supports it.
+//
+// The row is still read and locked first: the history entry needs its
+// prior state, and the hook is Go code that has to see the incremented
+// record, so a derived status cannot be folded into the UPDATE as a CASE.
+func (r *RecordRepository) IncrementProgress(ctx context.Context, id int, delta int) (*models.Record, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError("increment progress", id, err)
+	}
+	defer tx.Rollback()
+
+	before, err := r.lockRecord(ctx, tx, "increment progress", id)
+	if err != nil {
+		return nil, err
+	}
+
+	incremented := *before
+	incremented.WatchedEpisodes = incrementProgress(incremented, delta)
+	record := incremented
+	r.options.runBeforeWrite(ctx, &record)
+	if err := record.Validate(); err != nil {
+		return nil, invalid("increment progress", id, err)
+	}
+
+	columns, args := changedColumns(&incremented, &record)
+	if record.WatchedEpisodes == incremented.WatchedEpisodes {
+		columns = append([]string{`watched_episodes = ` + r.dialect.Greatest + `(0, CASE
+			WHEN total_episodes > 0 THEN ` + r.dialect.Least + `(watched_episodes + ?, total_episodes)
+			ELSE watched_episodes + ?
+		END)`}, columns...)
+		args = append([]any{delta, delta}, args...)
+	}
+	update := `UPDATE watch_list SET ` + strings.Join(columns, ", ") + `, version = version + 1 WHERE id = ?`
+	args = append(args, id)
+
+	if r.dialect.Returning {
+		err = scanRecord(tx.QueryRowContext(ctx, r.dialect.bind(update+` RETURNING `+recordColumns), args...), &record)
+	} else {
+		// The row is locked, so it now holds exactly record.
+		_, err = tx.ExecContext(ctx, r.dialect.bind(update), args...)
+		record.Version++
+	}
+	if err != nil {
+		return nil, translateError("increment progress", id, err)
+	}
+
+	if err := r.recordHistory(ctx, tx, models.ActionUpdate, id, before, &record); err != nil {
+		return nil, translateError("increment progress", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, translateError("increment progress", id, err)
+	}
+
+	return &record, nil
+}
+
+// incrementProgress returns the watched episodes of record after adding
+// delta, clamped as IncrementProgress describes.
+func incrementProgress(record models.Record, delta int) int {
+	watched := record.WatchedEpisodes + delta
+	if record.TotalEpisodes > 0 {
+		watched = min(watched, record.TotalEpisodes)
+	}
+	return max(0, watched)
+}
+
+// changedColumns returns the assignments, and their arguments, that turn
+// the stored row before into after.
+func changedColumns(before, after *models.Record) ([]string, []any) {
+	var columns []string
+	var args []any
+	if after.Title != before.Title {
+		columns = append(columns, "title = ?")
+		args = append(args, after.Title)
+	}
+	if after.TotalEpisodes != before.TotalEpisodes {
+		columns = append(columns, "total_episodes = ?")
+		args = append(args, after.TotalEpisodes)
+	}
+	if after.WatchedEpisodes != before.WatchedEpisodes {
+		columns = append(columns, "watched_episodes = ?")
+		args = append(args, after.WatchedEpisodes)
+	}
+	if after.Type != before.Type {
+		columns = append(columns, "type = ?")
+		args = append(args, after.Type)
+	}
+	if after.Status != before.Status {
+		columns = append(columns, "status = ?")
+		args = append(args, after.Status)
+	}
+	return columns, args
+}
+
+// DeleteRecord moves the record to the trash. It stays there, invisible
+// to every other method, until it is restored or purged.
 func (r *RecordRepository) DeleteRecord(ctx context.Context, id int) error {
-	query := `DELETE FROM watch_list WHERE id = ?`
+	return r.withTx(ctx, "delete record", id, func(tx *sql.Tx) error {
+		return r.trashRecord(ctx, tx, id, 0)
+	})
+}
+
+// DeleteRecordVersion trashes the record only if its stored version
+// matches.
+func (r *RecordRepository) DeleteRecordVersion(ctx context.Context, id int, version int) error {
+	return r.withTx(ctx, "delete record", id, func(tx *sql.Tx) error {
+		return r.trashRecord(ctx, tx, id, version)
+	})
+}
 
-	result, err := r.db.ExecContext(ctx, query, id)
-	rowsAffected, err := result.RowsAffected()
+// trashRecord soft-deletes a record, conditionally on its version when
+// version is non-zero.
+func (r *RecordRepository) trashRecord(ctx context.Context, tx *sql.Tx, id int, version int) error {
+	before, err := r.lockRecord(ctx, tx, "delete record", id)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("no record found with ID %d", id)
+	if version > 0 && version != before.Version {
+		return versionMismatch("delete record", id)
+	}
+
+	// Records in the trash release their title.
+	query := `UPDATE watch_list SET deleted_at = ?, unique_title_key = NULL, version = version + 1 WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, r.dialect.bind(query), now(), id); err != nil {
+		return translateError("delete record", id, err)
+	}
+
+	if err := r.recordHistory(ctx, tx, models.ActionDelete, id, before, nil); err != nil {
+		return translateError("delete record", id, err)
 	}
 
 	return nil
 }
+
+// ListTrash returns the trashed records, most recently deleted first.
+func (r *RecordRepository) ListTrash(ctx context.Context) ([]models.TrashedRecord, error) {
+	query := `SELECT ` + recordColumns + `, deleted_at FROM watch_list
+	WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, r.dialect.bind(query))
+	if err != nil {
+		return nil, translateError("list trash", 0, err)
+	}
+	defer rows.Close()
+
+	var records []models.TrashedRecord
+	for rows.Next() {
+		var record models.TrashedRecord
+		err := rows.Scan(&record.ID,
+			&record.Title, &record.TotalEpisodes,
+			&record.WatchedEpisodes, &record.Type,
+			&record.Status, &record.Version, &record.DeletedAt)
+		if err != nil {
+			return nil, translateError("list trash", 0, err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError("list trash", 0, err)
+	}
+
+	return records, nil
+}
+
+// RestoreRecord takes a record out of the trash. Records that are not in
+// the trash are reported as not found.
+func (r *RecordRepository) RestoreRecord(ctx context.Context, id int) (*models.Record, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError("restore record", id, err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + recordColumns + ` FROM watch_list WHERE id = ? AND deleted_at IS NOT NULL` + r.dialect.LockRow
+
+	var before models.Record
+	err = scanRecord(tx.QueryRowContext(ctx, r.dialect.bind(query), id), &before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("restore record", id)
+	}
+	if err != nil {
+		return nil, translateError("restore record", id, err)
+	}
+
+	key, unique, err := r.claimTitle(ctx, tx, "restore record", id, before.Title)
+	if err != nil {
+		return nil, err
+	}
+
+	update := `UPDATE watch_list SET deleted_at = NULL, title_key = ?, unique_title_key = ?, version = version + 1 WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, r.dialect.bind(update), key, unique, id); err != nil {
+		return nil, titleConflict("restore record", id, before.Title, err)
+	}
+
+	record := before
+	record.Version++
+	if err := r.recordHistory(ctx, tx, models.ActionRestore, id, &before, &record); err != nil {
+		return nil, translateError("restore record", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, translateError("restore record", id, err)
+	}
+
+	return &record, nil
+}
+
+// PurgeTrash permanently deletes records trashed before the given time and
+// returns how many were removed. Their history is kept.
+func (r *RecordRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, translateError("purge trash", 0, err)
+	}
+	defer tx.Rollback()
+
+	expired := `deleted_at IS NOT NULL AND deleted_at < ?`
+	query := `SELECT ` + recordColumns + ` FROM watch_list WHERE ` + expired + r.dialect.LockRow
+	rows, err := tx.QueryContext(ctx, r.dialect.bind(query), before.UTC())
+	if err != nil {
+		return 0, translateError("purge trash", 0, err)
+	}
+	records, err := scanRecords(rows)
+	rows.Close()
+	if err != nil {
+		return 0, translateError("purge trash", 0, err)
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	for _, record := range records {
+		if err := r.recordHistory(ctx, tx, models.ActionPurge, record.ID, &record, nil); err != nil {
+			return 0, translateError("purge trash", record.ID, err)
+		}
+	}
+
+	purge := `DELETE FROM watch_list WHERE ` + expired
+	if _, err := tx.ExecContext(ctx, r.dialect.bind(purge), before.UTC()); err != nil {
+		return 0, translateError("purge trash", 0, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, translateError("purge trash", 0, err)
+	}
+
+	return len(records), nil
+}
+
+// now returns the current time in UTC so every backend compares stored
+// timestamps the same way.
+func now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,1204 @@
+// Package repositorytest is a conformance suite for implementations of
+// repository.RecordRepositoryInterface. Every backend runs the same
+// scenarios so they behave identically, down to the errors they return.
+package repositorytest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty repository for one test. It should register any
+// cleanup with t.Cleanup.
+type Factory func(t *testing.T) repository.RecordRepositoryInterface
+
+// Run exercises every method of the repository returned by newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.RecordRepositoryInterface)
+	}{
+		{"CreateRecord", testCreateRecord},
+		{"CreateRecordValidation", testCreateRecordValidation},
+		{"ZeroValues", testZeroValues},
+		{"UnicodeTitles", testUnicodeTitles},
+		{"GetRecords", testGetRecords},
+		{"GetRecordByID", testGetRecordByID},
+		{"UpdateRecord", testUpdateRecord},
+		{"PatchRecord", testPatchRecord},
+		{"IncrementProgress", testIncrementProgress},
+		{"DeleteRecord", testDeleteRecord},
+		{"DeleteRecordVersion", testDeleteRecordVersion},
+		{"MissingRecords", testMissingRecords},
+		{"Trash", testTrash},
+		{"PurgeTrash", testPurgeTrash},
+		{"History", testHistory},
+		{"HistoryOutlivesPurge", testHistoryOutlivesPurge},
+		{"ApplyBatch", testApplyBatch},
+		{"MergeRecords", testMergeRecords},
+		{"FindByTitle", testFindByTitle},
+		{"Snapshot", testSnapshot},
+		{"ListRecordsFilters", testListRecordsFilters},
+		{"ListRecordsPagination", testListRecordsPagination},
+		{"ListRecordsErrors", testListRecordsErrors},
+		{"ListRecordsAsOf", testListRecordsAsOf},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentIncrements", testConcurrentIncrements},
+		{"ConcurrentConditionalUpdates", testConcurrentConditionalUpdates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func create(t *testing.T, repo repository.RecordRepositoryInterface, record models.Record) models.Record {
+	t.Helper()
+	if err := repo.CreateRecord(context.Background(), &record); err != nil {
+		t.Fatalf("Failed to create record %q: %v", record.Title, err)
+	}
+	return record
+}
+
+func get(t *testing.T, repo repository.RecordRepositoryInterface, id int) models.Record {
+	t.Helper()
+	record, err := repo.GetRecordByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get record %d: %v", id, err)
+	}
+	return *record
+}
+
+func expectError(t *testing.T, err, kind error, id int) {
+	t.Helper()
+	if !errors.Is(err, kind) {
+		t.Fatalf("Expected %v, got %v", kind, err)
+	}
+	var repoErr *repository.Error
+	if !errors.As(err, &repoErr) || repoErr.ID != id {
+		t.Errorf("Expected *repository.Error for ID %d, got %#v", id, err)
+	}
+}
+
+func testCreateRecord(t *testing.T, repo repository.RecordRepositoryInterface) {
+	first := create(t, repo, models.Record{Title: "  Frieren ", TotalEpisodes: 28, WatchedEpisodes: 4, Type: "TV", Status: "Watching"})
+	second := create(t, repo, models.Record{Title: "Your Name", TotalEpisodes: 1, Type: "movie", Status: "planning"})
+
+	if first.ID <= 0 || second.ID <= 0 || first.ID == second.ID {
+		t.Errorf("Expected distinct positive IDs, got %d and %d", first.ID, second.ID)
+	}
+	if first.Version != 1 {
+		t.Errorf("Expected version 1, got %d", first.Version)
+	}
+	if first.Title != "Frieren" || first.Type != "tv" || first.Status != "watching" {
+		t.Errorf("Expected normalized fields, got %+v", first)
+	}
+	if got := get(t, repo, first.ID); got != first {
+		t.Errorf("Expected stored record %+v, got %+v", first, got)
+	}
+}
+
+func testCreateRecordValidation(t *testing.T, repo repository.RecordRepositoryInterface) {
+	record := models.Record{Title: "", TotalEpisodes: 3, WatchedEpisodes: 5, Type: "music", Status: "finished"}
+	err := repo.CreateRecord(context.Background(), &record)
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Fatalf("Expected ErrValidation, got %v", err)
+	}
+	var validationErr *models.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) < 4 {
+		t.Errorf("Expected every field error at once, got %v", err)
+	}
+
+	records, err := repo.GetRecords(context.Background())
+	if err != nil || len(records) != 0 {
+		t.Errorf("Expected nothing stored, got %v, %v", records, err)
+	}
+}
+
+func testZeroValues(t *testing.T, repo repository.RecordRepositoryInterface) {
+	record := create(t, repo, models.Record{Title: "Unannounced", Type: "ona", Status: "planning"})
+	got := get(t, repo, record.ID)
+	if got.TotalEpisodes != 0 || got.WatchedEpisodes != 0 {
+		t.Errorf("Expected zero episode counts, got %+v", got)
+	}
+
+	// A total of 0 means unknown, so progress has no upper bound.
+	progressed, err := repo.IncrementProgress(context.Background(), record.ID, 12)
+	if err != nil {
+		t.Fatalf("Failed to increment progress: %v", err)
+	}
+	if progressed.WatchedEpisodes != 12 {
+		t.Errorf("Expected 12 watched episodes, got %d", progressed.WatchedEpisodes)
+	}
+
+	zero := 0
+	patched, err := repo.PatchRecord(context.Background(), record.ID, models.RecordPatch{WatchedEpisodes: &zero})
+	if err != nil {
+		t.Fatalf("Failed to patch record: %v", err)
+	}
+	if patched.WatchedEpisodes != 0 {
+		t.Errorf("Expected explicit zero to be written, got %d", patched.WatchedEpisodes)
+	}
+	if _, err := repo.IncrementProgress(context.Background(), record.ID, 0); err != nil {
+		t.Errorf("Expected zero delta to succeed, got %v", err)
+	}
+}
+
+func testUnicodeTitles(t *testing.T, repo repository.RecordRepositoryInterface) {
+	titles := []string{
+		"進撃の巨人",
+		"Shingeki no Kyojin: 完結編",
+		"Pokémon: Mewtwo Strikes Back — Evolution",
+		"🌸 Sakura Quest 🌸",
+		strings.Repeat("無", models.MaxTitleLength),
+	}
+	for _, title := range titles {
+		record := create(t, repo, models.Record{Title: title, Type: "tv", Status: "planning"})
+		if got := get(t, repo, record.ID); got.Title != title {
+			t.Errorf("Expected title %q to round-trip, got %q", title, got.Title)
+		}
+	}
+
+	page, err := repo.ListRecords(context.Background(), repository.ListOptions{TitleContains: "完結"})
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(page.Records) != 1 || page.Records[0].Title != titles[1] {
+		t.Errorf("Expected unicode title search to match %q, got %+v", titles[1], page.Records)
+	}
+
+	tooLong := models.Record{Title: strings.Repeat("無", models.MaxTitleLength+1), Type: "tv", Status: "planning"}
+	if err := repo.CreateRecord(context.Background(), &tooLong); !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected ErrValidation for an overlong title, got %v", err)
+	}
+}
+
+func testGetRecords(t *testing.T, repo repository.RecordRepositoryInterface) {
+	records, err := repo.GetRecords(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get records: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("Expected an empty repository, got %d records", len(records))
+	}
+
+	want := map[int]models.Record{}
+	for _, title := range []string{"Monster", "Pluto", "20th Century Boys"} {
+		record := create(t, repo, models.Record{Title: title, Type: "tv", Status: "planning"})
+		want[record.ID] = record
+	}
+
+	records, err = repo.GetRecords(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get records: %v", err)
+	}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got %d", len(want), len(records))
+	}
+	for _, record := range records {
+		if want[record.ID] != record {
+			t.Errorf("Expected %+v, got %+v", want[record.ID], record)
+		}
+	}
+}
+
+func testGetRecordByID(t *testing.T, repo repository.RecordRepositoryInterface) {
+	record := create(t, repo, models.Record{Title: "Vinland Saga", TotalEpisodes: 24, WatchedEpisodes: 24, Type: "tv", Status: "completed"})
+	if got := get(t, repo, record.ID); got != record {
+		t.Errorf("Expected %+v, got %+v", record, got)
+	}
+
+	for _, id := range []int{0, -1, record.ID + 1000} {
+		_, err := repo.GetRecordByID(context.Background(), id)
+		expectError(t, err, repository.ErrRecordNotFound, id)
+	}
+}
+
+func testUpdateRecord(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	record := create(t, repo, models.Record{Title: "Dorohedoro", TotalEpisodes: 12, Type: "tv", Status: "planning"})
+	stale := record
+
+	record.Title = "Dorohedoro (2020)"
+	record.WatchedEpisodes = 6
+	record.Status = "WATCHING"
+	if err := repo.UpdateRecord(ctx, &record); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if record.Version != 2 || record.Status != "watching" {
+		t.Errorf("Expected version 2 and normalized status, got %+v", record)
+	}
+	if got := get(t, repo, record.ID); got != record {
+		t.Errorf("Expected %+v, got %+v", record, got)
+	}
+
+	stale.Title = "Lost update"
+	expectError(t, repo.UpdateRecord(ctx, &stale), repository.ErrVersionMismatch, record.ID)
+	expectError(t, repo.UpdateRecord(ctx, &stale), repository.ErrConflict, record.ID)
+
+	// A zero version skips the check.
+	unconditional := record
+	unconditional.Version = 0
+	unconditional.WatchedEpisodes = 7
+	if err := repo.UpdateRecord(ctx, &unconditional); err != nil {
+		t.Fatalf("Failed unconditional update: %v", err)
+	}
+	if unconditional.Version != 3 {
+		t.Errorf("Expected version 3, got %d", unconditional.Version)
+	}
+
+	invalid := unconditional
+	invalid.WatchedEpisodes = 13
+	expectError(t, repo.UpdateRecord(ctx, &invalid), repository.ErrValidation, record.ID)
+	if got := get(t, repo, record.ID); got.WatchedEpisodes != 7 {
+		t.Errorf("Expected invalid update to leave the record alone, got %+v", got)
+	}
+}
+
+func testPatchRecord(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	record := create(t, repo, models.Record{Title: "Mob Psycho 100", TotalEpisodes: 12, WatchedEpisodes: 2, Type: "tv", Status: "watching"})
+
+	watched := 5
+	patched, err := repo.PatchRecord(ctx, record.ID, models.RecordPatch{WatchedEpisodes: &watched})
+	if err != nil {
+		t.Fatalf("Failed to patch record: %v", err)
+	}
+	want := record
+	want.WatchedEpisodes = 5
+	want.Version = 2
+	if *patched != want {
+		t.Errorf("Expected only watched_episodes to change, got %+v", patched)
+	}
+	if got := get(t, repo, record.ID); got != want {
+		t.Errorf("Expected stored %+v, got %+v", want, got)
+	}
+
+	unchanged, err := repo.PatchRecord(ctx, record.ID, models.RecordPatch{})
+	if err != nil {
+		t.Fatalf("Failed empty patch: %v", err)
+	}
+	if unchanged.Version != 2 {
+		t.Errorf("Expected an empty patch to keep version 2, got %d", unchanged.Version)
+	}
+
+	status := "completed"
+	_, err = repo.PatchRecord(ctx, record.ID, models.RecordPatch{Status: &status, Version: 1})
+	expectError(t, err, repository.ErrVersionMismatch, record.ID)
+
+	tooMany := 13
+	_, err = repo.PatchRecord(ctx, record.ID, models.RecordPatch{WatchedEpisodes: &tooMany})
+	expectError(t, err, repository.ErrValidation, record.ID)
+
+	badType := "music"
+	_, err = repo.PatchRecord(ctx, record.ID, models.RecordPatch{Type: &badType})
+	expectError(t, err, repository.ErrValidation, record.ID)
+
+	if got := get(t, repo, record.ID); got != want {
+		t.Errorf("Expected rejected patches to leave %+v, got %+v", want, got)
+	}
+}
+
+func testIncrementProgress(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	record := create(t, repo, models.Record{Title: "Cowboy Bebop", TotalEpisodes: 26, WatchedEpisodes: 10, Type: "tv", Status: "watching"})
+
+	steps := []struct {
+		delta    int
+		expected int
+	}{
+		{1, 11},
+		{-3, 8},
+		{100, 26},
+		{1, 26},
+		{-100, 0},
+	}
+	for i, step := range steps {
+		got, err := repo.IncrementProgress(ctx, record.ID, step.delta)
+		if err != nil {
+			t.Fatalf("Failed to increment by %d: %v", step.delta, err)
+		}
+		if got.WatchedEpisodes != step.expected {
+			t.Errorf("Expected %d after delta %d, got %d", step.expected, step.delta, got.WatchedEpisodes)
+		}
+		if got.Version != i+2 {
+			t.Errorf("Expected version %d, got %d", i+2, got.Version)
+		}
+		if got.Title != record.Title || got.Status != record.Status {
+			t.Errorf("Expected other fields unchanged, got %+v", got)
+		}
+	}
+}
+
+func testDeleteRecord(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	keep := create(t, repo, models.Record{Title: "Haikyu!!", Type: "tv", Status: "watching"})
+	record := create(t, repo, models.Record{Title: "Kuroko's Basketball", Type: "tv", Status: "dropped"})
+
+	if err := repo.DeleteRecord(ctx, record.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	_, err := repo.GetRecordByID(ctx, record.ID)
+	expectError(t, err, repository.ErrRecordNotFound, record.ID)
+	expectError(t, repo.DeleteRecord(ctx, record.ID), repository.ErrRecordNotFound, record.ID)
+
+	if got := get(t, repo, keep.ID); got != keep {
+		t.Errorf("Expected other records untouched, got %+v", got)
+	}
+
+	// IDs of deleted records are not handed out again.
+	next := create(t, repo, models.Record{Title: "Kuroko's Basketball", Type: "tv", Status: "dropped"})
+	if next.ID == record.ID || next.ID == keep.ID {
+		t.Errorf("Expected a fresh ID, got %d", next.ID)
+	}
+}
+
+func testDeleteRecordVersion(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	record := create(t, repo, models.Record{Title: "Berserk", TotalEpisodes: 25, Type: "tv", Status: "planning"})
+	updated, err := repo.IncrementProgress(ctx, record.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to increment progress: %v", err)
+	}
+
+	expectError(t, repo.DeleteRecordVersion(ctx, record.ID, record.Version), repository.ErrVersionMismatch, record.ID)
+	get(t, repo, record.ID)
+
+	if err := repo.DeleteRecordVersion(ctx, record.ID, updated.Version); err != nil {
+		t.Fatalf("Failed to delete current version: %v", err)
+	}
+	expectError(t, repo.DeleteRecordVersion(ctx, record.ID, updated.Version), repository.ErrRecordNotFound, record.ID)
+}
+
+func testMissingRecords(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	const id = 987654
+	title := "Ghost"
+
+	_, err := repo.GetRecordByID(ctx, id)
+	expectError(t, err, repository.ErrRecordNotFound, id)
+	expectError(t, repo.UpdateRecord(ctx, &models.Record{ID: id, Title: title, Type: "tv", Status: "planning"}), repository.ErrRecordNotFound, id)
+	expectError(t, repo.UpdateRecord(ctx, &models.Record{ID: id, Title: title, Type: "tv", Status: "planning", Version: 3}), repository.ErrRecordNotFound, id)
+	_, err = repo.PatchRecord(ctx, id, models.RecordPatch{Title: &title})
+	expectError(t, err, repository.ErrRecordNotFound, id)
+	_, err = repo.IncrementProgress(ctx, id, 1)
+	expectError(t, err, repository.ErrRecordNotFound, id)
+	expectError(t, repo.DeleteRecord(ctx, id), repository.ErrRecordNotFound, id)
+	expectError(t, repo.DeleteRecordVersion(ctx, id, 1), repository.ErrRecordNotFound, id)
+}
+
+func testTrash(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	keep := create(t, repo, models.Record{Title: "Made in Abyss", TotalEpisodes: 13, Type: "tv", Status: "watching"})
+	record := create(t, repo, models.Record{Title: "Eden of the East", TotalEpisodes: 11, WatchedEpisodes: 4, Type: "tv", Status: "on-hold"})
+
+	trash, err := repo.ListTrash(ctx)
+	if err != nil || len(trash) != 0 {
+		t.Fatalf("Expected an empty trash, got %v, %v", trash, err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	if err := repo.DeleteRecord(ctx, record.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+
+	// Trashed records are invisible everywhere else.
+	records, err := repo.GetRecords(ctx)
+	if err != nil || !slices.Equal(ids(records), []int{keep.ID}) {
+		t.Errorf("Expected only record %d, got %v, %v", keep.ID, ids(records), err)
+	}
+	page, err := repo.ListRecords(ctx, repository.ListOptions{})
+	if err != nil || !slices.Equal(ids(page.Records), []int{keep.ID}) {
+		t.Errorf("Expected only record %d listed, got %+v, %v", keep.ID, page, err)
+	}
+	title := "Eden of the East: The King of Eden"
+	_, err = repo.GetRecordByID(ctx, record.ID)
+	expectError(t, err, repository.ErrRecordNotFound, record.ID)
+	expectError(t, repo.UpdateRecord(ctx, &models.Record{ID: record.ID, Title: title, Type: "movie", Status: "planning"}), repository.ErrRecordNotFound, record.ID)
+	_, err = repo.PatchRecord(ctx, record.ID, models.RecordPatch{Title: &title})
+	expectError(t, err, repository.ErrRecordNotFound, record.ID)
+	_, err = repo.IncrementProgress(ctx, record.ID, 1)
+	expectError(t, err, repository.ErrRecordNotFound, record.ID)
+	expectError(t, repo.DeleteRecord(ctx, record.ID), repository.ErrRecordNotFound, record.ID)
+	expectError(t, repo.DeleteRecordVersion(ctx, record.ID, record.Version+1), repository.ErrRecordNotFound, record.ID)
+
+	trash, err = repo.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != record.ID || trash[0].Title != record.Title {
+		t.Fatalf("Expected record %d in the trash, got %+v", record.ID, trash)
+	}
+	if trash[0].DeletedAt.Before(before) || trash[0].DeletedAt.After(time.Now().Add(time.Second)) {
+		t.Errorf("Expected deleted_at close to now, got %v", trash[0].DeletedAt)
+	}
+
+	restored, err := repo.RestoreRecord(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("Failed to restore record: %v", err)
+	}
+	if restored.Title != record.Title || restored.WatchedEpisodes != 4 || restored.Status != "on-hold" {
+		t.Errorf("Expected progress to survive the trash, got %+v", restored)
+	}
+	if restored.Version <= record.Version {
+		t.Errorf("Expected restore to bump version past %d, got %d", record.Version, restored.Version)
+	}
+	if got := get(t, repo, record.ID); got != *restored {
+		t.Errorf("Expected %+v, got %+v", *restored, got)
+	}
+
+	_, err = repo.RestoreRecord(ctx, record.ID)
+	expectError(t, err, repository.ErrRecordNotFound, record.ID)
+	_, err = repo.RestoreRecord(ctx, keep.ID+1000)
+	expectError(t, err, repository.ErrRecordNotFound, keep.ID+1000)
+
+	// Conditional deletes trash too.
+	if err := repo.DeleteRecordVersion(ctx, record.ID, restored.Version); err != nil {
+		t.Fatalf("Failed to delete current version: %v", err)
+	}
+	if trash, _ := repo.ListTrash(ctx); len(trash) != 1 || trash[0].ID != record.ID {
+		t.Errorf("Expected record %d back in the trash, got %+v", record.ID, trash)
+	}
+}
+
+func testPurgeTrash(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	keep := create(t, repo, models.Record{Title: "Planetes", Type: "tv", Status: "planning"})
+	first := create(t, repo, models.Record{Title: "Texhnolyze", Type: "tv", Status: "dropped"})
+	second := create(t, repo, models.Record{Title: "Ergo Proxy", Type: "tv", Status: "dropped"})
+	for _, id := range []int{first.ID, second.ID} {
+		if err := repo.DeleteRecord(ctx, id); err != nil {
+			t.Fatalf("Failed to delete record: %v", err)
+		}
+	}
+
+	purged, err := repo.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Errorf("Expected nothing purged before the deletions, got %d, %v", purged, err)
+	}
+
+	purged, err = repo.PurgeTrash(ctx, time.Now().Add(time.Hour))
+	if err != nil || purged != 2 {
+		t.Errorf("Expected 2 records purged, got %d, %v", purged, err)
+	}
+	if trash, _ := repo.ListTrash(ctx); len(trash) != 0 {
+		t.Errorf("Expected an empty trash, got %+v", trash)
+	}
+	_, err = repo.RestoreRecord(ctx, first.ID)
+	expectError(t, err, repository.ErrRecordNotFound, first.ID)
+	if got := get(t, repo, keep.ID); got != keep {
+		t.Errorf("Expected active records untouched, got %+v", got)
+	}
+}
+
+func testHistory(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := repository.WithRequestID(repository.WithActor(context.Background(), "alice"), "req-1")
+	started := time.Now().Add(-time.Second)
+
+	record := models.Record{Title: "Mushishi", TotalEpisodes: 26, Type: "tv", Status: "planning"}
+	if err := repo.CreateRecord(ctx, &record); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	created := record
+	record.Status = "watching"
+	if err := repo.UpdateRecord(ctx, &record); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	updated := record
+	title := "Mushi-Shi"
+	patched, err := repo.PatchRecord(ctx, record.ID, models.RecordPatch{Title: &title})
+	if err != nil {
+		t.Fatalf("Failed to patch record: %v", err)
+	}
+	if _, err := repo.PatchRecord(ctx, record.ID, models.RecordPatch{}); err != nil {
+		t.Fatalf("Failed to apply empty patch: %v", err)
+	}
+	progressed, err := repo.IncrementProgress(ctx, record.ID, 3)
+	if err != nil {
+		t.Fatalf("Failed to increment progress: %v", err)
+	}
+
+	// Failed writes leave no trace.
+	stale := *progressed
+	stale.Version = created.Version
+	expectError(t, repo.UpdateRecord(ctx, &stale), repository.ErrVersionMismatch, record.ID)
+
+	if err := repo.DeleteRecord(ctx, record.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	restored, err := repo.RestoreRecord(repository.WithActor(ctx, "bob"), record.ID)
+	if err != nil {
+		t.Fatalf("Failed to restore record: %v", err)
+	}
+
+	// Deleting bumps the version of the trashed copy.
+	trashed := *progressed
+	trashed.Version++
+
+	history, err := repo.GetRecordHistory(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	want := []struct {
+		action        string
+		before, after *models.Record
+	}{
+		{models.ActionCreate, nil, &created},
+		{models.ActionUpdate, &created, &updated},
+		{models.ActionUpdate, &updated, patched},
+		{models.ActionUpdate, patched, progressed},
+		{models.ActionDelete, progressed, nil},
+		{models.ActionRestore, &trashed, restored},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("Expected %d history entries, got %d: %+v", len(want), len(history), history)
+	}
+	for i, entry := range history {
+		w := want[i]
+		if entry.RecordID != record.ID || entry.Action != w.action {
+			t.Errorf("Entry %d: expected %s of record %d, got %s of record %d", i, w.action, record.ID, entry.Action, entry.RecordID)
+		}
+		if !sameSnapshot(entry.Before, w.before) {
+			t.Errorf("Entry %d: expected before %+v, got %+v", i, w.before, entry.Before)
+		}
+		if !sameSnapshot(entry.After, w.after) {
+			t.Errorf("Entry %d: expected after %+v, got %+v", i, w.after, entry.After)
+		}
+		if entry.ChangedAt.Before(started) || entry.ChangedAt.After(time.Now().Add(time.Second)) {
+			t.Errorf("Entry %d: expected changed_at close to now, got %v", i, entry.ChangedAt)
+		}
+		if entry.RequestID != "req-1" {
+			t.Errorf("Entry %d: expected request ID req-1, got %q", i, entry.RequestID)
+		}
+		if i > 0 && entry.ID <= history[i-1].ID {
+			t.Errorf("Entry %d: expected increasing ids, got %d after %d", i, entry.ID, history[i-1].ID)
+		}
+	}
+	if history[0].Actor != "alice" || history[5].Actor != "bob" {
+		t.Errorf("Expected actors alice and bob, got %q and %q", history[0].Actor, history[5].Actor)
+	}
+
+	_, err = repo.GetRecordHistory(ctx, record.ID+1000)
+	expectError(t, err, repository.ErrRecordNotFound, record.ID+1000)
+}
+
+func sameSnapshot(got, want *models.Record) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return *got == *want
+}
+
+func testHistoryOutlivesPurge(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	record := create(t, repo, models.Record{Title: "Haibane Renmei", Type: "tv", Status: "completed"})
+	if err := repo.DeleteRecord(ctx, record.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	if _, err := repo.PurgeTrash(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+
+	history, err := repo.GetRecordHistory(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("Failed to get history of a purged record: %v", err)
+	}
+	actions := make([]string, len(history))
+	for i, entry := range history {
+		actions[i] = entry.Action
+	}
+	want := []string{models.ActionCreate, models.ActionDelete, models.ActionPurge}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("Expected actions %v, got %v", want, actions)
+	}
+	if purge := history[2]; purge.Before == nil || purge.Before.Title != record.Title || purge.After != nil {
+		t.Errorf("Expected the purge to snapshot only the trashed record, got %+v", purge)
+	}
+}
+
+func testApplyBatch(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	existing := create(t, repo, models.Record{Title: "Hyouka", TotalEpisodes: 22, WatchedEpisodes: 5, Type: "tv", Status: "watching"})
+	doomed := create(t, repo, models.Record{Title: "Another", TotalEpisodes: 12, Type: "tv", Status: "planning"})
+
+	finished := existing
+	finished.WatchedEpisodes = 22
+	finished.Status = "completed"
+	ops := []repository.BatchOperation{
+		{Op: repository.BatchCreate, Record: models.Record{Title: "Kanon", TotalEpisodes: 24, Type: "tv", Status: "planning"}},
+		{Op: repository.BatchUpdate, ID: existing.ID, Version: existing.Version, Record: finished},
+		{Op: repository.BatchDelete, ID: doomed.ID},
+	}
+	results, err := repo.ApplyBatch(ctx, ops, true)
+	if err != nil {
+		t.Fatalf("Failed to apply batch: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", results)
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("Operation %d: unexpected error %v", i, result.Err)
+		}
+	}
+	created := results[0].Record
+	if created == nil || created.ID == 0 || created.Version != 1 || created.Title != "Kanon" {
+		t.Fatalf("Expected the created record, got %+v", created)
+	}
+	if got := get(t, repo, created.ID); got != *created {
+		t.Errorf("Expected %+v stored, got %+v", *created, got)
+	}
+	if updated := results[1].Record; updated == nil || updated.Version != existing.Version+1 || updated.Status != "completed" {
+		t.Errorf("Expected the updated record, got %+v", updated)
+	}
+	if results[2].Record != nil {
+		t.Errorf("Expected no record for a delete, got %+v", results[2].Record)
+	}
+	_, err = repo.GetRecordByID(ctx, doomed.ID)
+	expectError(t, err, repository.ErrRecordNotFound, doomed.ID)
+
+	t.Run("Atomic rollback", func(t *testing.T) {
+		before, _ := repo.GetRecords(ctx)
+		current := get(t, repo, existing.ID)
+		stale := current
+		stale.Title = "Hyouka: You can't escape"
+		ops := []repository.BatchOperation{
+			{Op: repository.BatchCreate, Record: models.Record{Title: "Clannad", Type: "tv", Status: "planning"}},
+			{Op: repository.BatchUpdate, ID: existing.ID, Version: current.Version, Record: stale},
+			{Op: repository.BatchUpdate, ID: existing.ID, Version: current.Version, Record: stale},
+			{Op: repository.BatchDelete, ID: existing.ID},
+		}
+		results, err := repo.ApplyBatch(ctx, ops, true)
+		if !errors.Is(err, repository.ErrVersionMismatch) {
+			t.Fatalf("Expected the version mismatch of operation 2, got %v", err)
+		}
+		if len(results) != len(ops) {
+			t.Fatalf("Expected %d results, got %+v", len(ops), results)
+		}
+		for i, result := range results {
+			want := repository.ErrBatchAborted
+			if i == 2 {
+				want = repository.ErrVersionMismatch
+			}
+			if !errors.Is(result.Err, want) || result.Record != nil {
+				t.Errorf("Operation %d: expected %v, got %+v", i, want, result)
+			}
+		}
+
+		after, _ := repo.GetRecords(ctx)
+		if !slices.Equal(after, before) {
+			t.Errorf("Expected nothing written, got %+v instead of %+v", after, before)
+		}
+		history, _ := repo.GetRecordHistory(ctx, existing.ID)
+		if last := history[len(history)-1]; last.After == nil || *last.After != current {
+			t.Errorf("Expected no history from the rolled back batch, got %+v", last)
+		}
+	})
+
+	t.Run("Per-item", func(t *testing.T) {
+		current := get(t, repo, existing.ID)
+		renamed := current
+		renamed.Title = "Hyouka (2012)"
+		ops := []repository.BatchOperation{
+			{Op: repository.BatchCreate, Record: models.Record{Title: "Air", Type: "tv", Status: "planning"}},
+			{Op: repository.BatchCreate, Record: models.Record{Title: "", Type: "tv", Status: "planning"}},
+			{Op: repository.BatchDelete, ID: doomed.ID},
+			{Op: repository.BatchUpdate, ID: existing.ID, Version: current.Version, Record: renamed},
+		}
+		results, err := repo.ApplyBatch(ctx, ops, false)
+		if err != nil {
+			t.Fatalf("Expected per-item failures in the results only, got %v", err)
+		}
+		if results[0].Err != nil || results[0].Record == nil {
+			t.Errorf("Expected operation 0 to succeed, got %+v", results[0])
+		}
+		if !errors.Is(results[1].Err, repository.ErrValidation) {
+			t.Errorf("Expected operation 1 to fail validation, got %+v", results[1])
+		}
+		if !errors.Is(results[2].Err, repository.ErrRecordNotFound) {
+			t.Errorf("Expected operation 2 to find nothing, got %+v", results[2])
+		}
+		if results[3].Err != nil {
+			t.Errorf("Expected operation 3 to succeed, got %+v", results[3])
+		}
+		get(t, repo, results[0].Record.ID)
+		if got := get(t, repo, existing.ID); got.Title != renamed.Title {
+			t.Errorf("Expected title %q, got %q", renamed.Title, got.Title)
+		}
+	})
+
+	t.Run("Unknown operation", func(t *testing.T) {
+		_, err := repo.ApplyBatch(ctx, []repository.BatchOperation{{Op: "upsert"}}, true)
+		if !errors.Is(err, repository.ErrValidation) {
+			t.Errorf("Expected a validation error, got %v", err)
+		}
+	})
+}
+
+func testMergeRecords(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	keep := create(t, repo, models.Record{Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 3, Type: "tv", Status: "watching"})
+	remove := create(t, repo, models.Record{Title: "blue lock", TotalEpisodes: 0, WatchedEpisodes: 10, Type: "ona", Status: "on-hold"})
+
+	merged, err := repo.MergeRecords(ctx, keep.ID, remove.ID)
+	if err != nil {
+		t.Fatalf("Failed to merge records: %v", err)
+	}
+	want := models.Record{ID: keep.ID, Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 10,
+		Type: "tv", Status: "watching", Version: keep.Version + 1}
+	if *merged != want {
+		t.Errorf("Expected %+v, got %+v", want, *merged)
+	}
+	if got := get(t, repo, keep.ID); got != want {
+		t.Errorf("Expected %+v stored, got %+v", want, got)
+	}
+	_, err = repo.GetRecordByID(ctx, remove.ID)
+	expectError(t, err, repository.ErrRecordNotFound, remove.ID)
+	trash, _ := repo.ListTrash(ctx)
+	if len(trash) != 1 || trash[0].ID != remove.ID {
+		t.Errorf("Expected the merged away record in the trash, got %+v", trash)
+	}
+
+	history, _ := repo.GetRecordHistory(ctx, remove.ID)
+	if last := history[len(history)-1]; last.Action != models.ActionDelete {
+		t.Errorf("Expected the merged away record to be deleted in its history, got %+v", last)
+	}
+
+	t.Run("Missing record", func(t *testing.T) {
+		_, err := repo.MergeRecords(ctx, keep.ID, remove.ID)
+		expectError(t, err, repository.ErrRecordNotFound, remove.ID)
+		if got := get(t, repo, keep.ID); got != want {
+			t.Errorf("Expected the kept record untouched, got %+v", got)
+		}
+	})
+
+	t.Run("Same record", func(t *testing.T) {
+		_, err := repo.MergeRecords(ctx, keep.ID, keep.ID)
+		expectError(t, err, repository.ErrValidation, keep.ID)
+	})
+}
+
+func testFindByTitle(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	first := create(t, repo, models.Record{Title: "Blue Lock", Type: "tv", Status: "planning"})
+	create(t, repo, models.Record{Title: "Monster", Type: "tv", Status: "planning"})
+	second := create(t, repo, models.Record{Title: "BLUE-LOCK!", Type: "tv", Status: "planning"})
+	trashed := create(t, repo, models.Record{Title: "blue lock", Type: "tv", Status: "planning"})
+	if err := repo.DeleteRecord(ctx, trashed.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+
+	records, err := repo.FindByTitle(ctx, " blue lock ")
+	if err != nil {
+		t.Fatalf("Failed to find by title: %v", err)
+	}
+	if got := ids(records); !slices.Equal(got, []int{first.ID, second.ID}) {
+		t.Errorf("Expected records %d and %d, got %v", first.ID, second.ID, got)
+	}
+
+	// A retitled record is found under its new title only.
+	title := "Blue Lock 2"
+	if _, err := repo.PatchRecord(ctx, second.ID, models.RecordPatch{Title: &title}); err != nil {
+		t.Fatalf("Failed to patch record: %v", err)
+	}
+	if records, _ := repo.FindByTitle(ctx, "Blue Lock"); !slices.Equal(ids(records), []int{first.ID}) {
+		t.Errorf("Expected only record %d, got %v", first.ID, ids(records))
+	}
+	if records, _ := repo.FindByTitle(ctx, "blue lock 2"); !slices.Equal(ids(records), []int{second.ID}) {
+		t.Errorf("Expected only record %d, got %v", second.ID, ids(records))
+	}
+
+	if records, err := repo.FindByTitle(ctx, "Baccano!"); err != nil || len(records) != 0 {
+		t.Errorf("Expected no records, got %+v, %v", records, err)
+	}
+}
+
+func testSnapshot(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	first := create(t, repo, models.Record{Title: "Blue Lock", Type: "tv", Status: "planning"})
+	trashed := create(t, repo, models.Record{Title: "Monster", Type: "tv", Status: "planning"})
+	last := create(t, repo, models.Record{Title: "Baccano!", Type: "tv", Status: "planning"})
+	if err := repo.DeleteRecord(ctx, trashed.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+
+	// Writes made while the snapshot is read are not part of it.
+	var wg sync.WaitGroup
+	var seen []models.TrashedRecord
+	err := repo.Snapshot(ctx, func(record models.TrashedRecord) error {
+		if len(seen) == 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				repo.CreateRecord(ctx, &models.Record{Title: "Akira", Type: "movie", Status: "planning"})
+				repo.DeleteRecord(ctx, last.ID)
+			}()
+		}
+		seen = append(seen, record)
+		return nil
+	})
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if len(seen) != 3 || seen[0].Record != first || seen[2].Record != last {
+		t.Fatalf("Expected the three records in ID order, got %+v", seen)
+	}
+	if !seen[0].DeletedAt.IsZero() || !seen[2].DeletedAt.IsZero() {
+		t.Errorf("Expected records outside the trash without DeletedAt, got %+v", seen)
+	}
+	if seen[1].ID != trashed.ID || seen[1].DeletedAt.IsZero() {
+		t.Errorf("Expected the trashed record with its DeletedAt, got %+v", seen[1])
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.Snapshot(ctx, func(models.TrashedRecord) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the callback error after one call, got %v after %d", err, calls)
+	}
+}
+
+// seed creates a fixed set of records. The lower-case and accented titles
+// sort differently under a case-insensitive or linguistic collation than in
+// the byte order every backend is expected to page in.
+func seed(t *testing.T, repo repository.RecordRepositoryInterface) []models.Record {
+	var records []models.Record
+	for _, record := range []models.Record{
+		{Title: "Naruto", TotalEpisodes: 220, WatchedEpisodes: 220, Type: "tv", Status: "completed"},
+		{Title: "akira", TotalEpisodes: 1, Type: "movie", Status: "planning"},
+		{Title: "Naruto Shippuden", TotalEpisodes: 500, WatchedEpisodes: 10, Type: "tv", Status: "watching"},
+		{Title: "Boruto", TotalEpisodes: 293, WatchedEpisodes: 10, Type: "tv", Status: "dropped"},
+		{Title: "Perfect Blue", TotalEpisodes: 1, WatchedEpisodes: 1, Type: "movie", Status: "completed"},
+		{Title: "Hellsing Ultimate", TotalEpisodes: 10, WatchedEpisodes: 3, Type: "ova", Status: "on-hold"},
+		{Title: "Sword 100% Art_Online", TotalEpisodes: 25, WatchedEpisodes: 10, Type: "tv", Status: "watching"},
+		{Title: "Élan Vital", TotalEpisodes: 12, WatchedEpisodes: 12, Type: "tv", Status: "completed"},
+	} {
+		records = append(records, create(t, repo, record))
+	}
+	return records
+}
+
+func testListRecordsFilters(t *testing.T, repo repository.RecordRepositoryInterface) {
+	records := seed(t, repo)
+
+	tests := []struct {
+		name     string
+		opts     repository.ListOptions
+		expected []int
+	}{
+		{"No filters", repository.ListOptions{}, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"Status", repository.ListOptions{Status: "completed"}, []int{0, 4, 7}},
+		{"Type", repository.ListOptions{Type: "movie"}, []int{1, 4}},
+		{"Status and type", repository.ListOptions{Status: "watching", Type: "tv"}, []int{2, 6}},
+		{"Title is case-insensitive", repository.ListOptions{TitleContains: "NARUTO"}, []int{0, 2}},
+		{"Percent is literal", repository.ListOptions{TitleContains: "%"}, []int{6}},
+		{"Underscore is literal", repository.ListOptions{TitleContains: "_"}, []int{6}},
+		{"No match", repository.ListOptions{TitleContains: "%_"}, nil},
+		{"Unknown status", repository.ListOptions{Status: "finished"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.ListRecords(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("Failed to list records: %v", err)
+			}
+			var want []int
+			for _, i := range tt.expected {
+				want = append(want, records[i].ID)
+			}
+			if got := ids(page.Records); !slices.Equal(got, want) {
+				t.Errorf("Expected ids %v, got %v", want, got)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("Expected no next cursor, got %q", page.NextCursor)
+			}
+		})
+	}
+}
+
+func testListRecordsPagination(t *testing.T, repo repository.RecordRepositoryInterface) {
+	records := seed(t, repo)
+
+	for field := range repository.SortableFields {
+		for _, descending := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s descending=%v", field, descending), func(t *testing.T) {
+				want := slices.Clone(records)
+				slices.SortFunc(want, func(a, b models.Record) int {
+					c := compareField(field, a, b)
+					if c == 0 {
+						c = cmp.Compare(a.ID, b.ID)
+					}
+					if descending {
+						c = -c
+					}
+					return c
+				})
+
+				opts := repository.ListOptions{SortBy: field, Descending: descending, Limit: 3}
+				var got []models.Record
+				for pages := 0; ; pages++ {
+					if pages > len(records) {
+						t.Fatal("Expected pagination to finish")
+					}
+					page, err := repo.ListRecords(context.Background(), opts)
+					if err != nil {
+						t.Fatalf("Failed to list records: %v", err)
+					}
+					if len(page.Records) > opts.Limit {
+						t.Fatalf("Expected at most %d records, got %d", opts.Limit, len(page.Records))
+					}
+					got = append(got, page.Records...)
+					if page.NextCursor == "" {
+						break
+					}
+					opts.Cursor = page.NextCursor
+				}
+
+				if !slices.Equal(ids(got), ids(want)) {
+					t.Errorf("Expected ids %v, got %v", ids(want), ids(got))
+				}
+			})
+		}
+	}
+}
+
+func testListRecordsAsOf(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	// Checkpoints are separated from writes so that rounding of stored
+	// timestamps cannot move a change across them.
+	checkpoint := func() time.Time {
+		time.Sleep(10 * time.Millisecond)
+		at := time.Now()
+		time.Sleep(10 * time.Millisecond)
+		return at
+	}
+
+	beginning := checkpoint()
+	monster := create(t, repo, models.Record{Title: "Monster", TotalEpisodes: 74, WatchedEpisodes: 10, Type: "tv", Status: "watching"})
+	baccano := create(t, repo, models.Record{Title: "Baccano!", TotalEpisodes: 13, Type: "tv", Status: "planning"})
+	dropped := create(t, repo, models.Record{Title: "Gantz", TotalEpisodes: 26, Type: "tv", Status: "dropped"})
+	firstYear := checkpoint()
+
+	finished := monster
+	finished.WatchedEpisodes = 74
+	finished.Status = "completed"
+	if err := repo.UpdateRecord(ctx, &finished); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	watching := "watching"
+	if _, err := repo.PatchRecord(ctx, baccano.ID, models.RecordPatch{Status: &watching}); err != nil {
+		t.Fatalf("Failed to patch record: %v", err)
+	}
+	for _, id := range []int{baccano.ID, dropped.ID} {
+		if err := repo.DeleteRecord(ctx, id); err != nil {
+			t.Fatalf("Failed to delete record: %v", err)
+		}
+	}
+	if _, err := repo.PurgeTrash(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	durarara := create(t, repo, models.Record{Title: "Durarara!!", TotalEpisodes: 24, Type: "tv", Status: "watching"})
+	secondYear := checkpoint()
+
+	list := func(opts repository.ListOptions) []models.Record {
+		t.Helper()
+		page, err := repo.ListRecords(ctx, opts)
+		if err != nil {
+			t.Fatalf("Failed to list records as of %v: %v", opts.AsOf, err)
+		}
+		return page.Records
+	}
+	expect := func(name string, got []models.Record, want ...models.Record) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: expected %+v, got %+v", name, want[i], got[i])
+			}
+		}
+	}
+
+	expect("Before any record", list(repository.ListOptions{AsOf: beginning}))
+	expect("First year", list(repository.ListOptions{AsOf: firstYear}), monster, baccano, dropped)
+	expect("Second year", list(repository.ListOptions{AsOf: secondYear}), finished, durarara)
+	expect("Now", list(repository.ListOptions{AsOf: time.Now()}), list(repository.ListOptions{})...)
+
+	expect("Filtered", list(repository.ListOptions{AsOf: firstYear, Status: "watching"}), monster)
+	expect("Sorted", list(repository.ListOptions{AsOf: firstYear, SortBy: "title", Descending: true}), monster, dropped, baccano)
+
+	page, err := repo.ListRecords(ctx, repository.ListOptions{AsOf: firstYear, SortBy: "title", Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list first page: %v", err)
+	}
+	expect("First page", page.Records, baccano, dropped)
+	if page.NextCursor == "" {
+		t.Fatal("Expected a cursor for the second page")
+	}
+	expect("Second page", list(repository.ListOptions{AsOf: firstYear, SortBy: "title", Limit: 2, Cursor: page.NextCursor}), monster)
+}
+
+func testListRecordsErrors(t *testing.T, repo repository.RecordRepositoryInterface) {
+	ctx := context.Background()
+	records := seed(t, repo)
+
+	_, err := repo.ListRecords(ctx, repository.ListOptions{SortBy: "password"})
+	if !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("Expected ErrValidation for an unknown sort field, got %v", err)
+	}
+
+	_, err = repo.ListRecords(ctx, repository.ListOptions{Cursor: "not a cursor"})
+	if !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+
+	page, err := repo.ListRecords(ctx, repository.ListOptions{SortBy: "title", Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	_, err = repo.ListRecords(ctx, repository.ListOptions{SortBy: "status", Limit: 1, Cursor: page.NextCursor})
+	if !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a cursor from another ordering, got %v", err)
+	}
+
+	page, err = repo.ListRecords(ctx, repository.ListOptions{Limit: repository.MaxListLimit + 1})
+	if err != nil {
+		t.Fatalf("Expected an oversized limit to be clamped, got %v", err)
+	}
+	if len(page.Records) != len(records) {
+		t.Errorf("Expected %d records, got %d", len(records), len(page.Records))
+	}
+}
+
+func testConcurrentCreates(t *testing.T, repo repository.RecordRepositoryInterface) {
+	const n = 20
+	var wg sync.WaitGroup
+	ids := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			record := models.Record{Title: fmt.Sprintf("Gintama %d", i), Type: "tv", Status: "planning"}
+			if err := repo.CreateRecord(context.Background(), &record); err != nil {
+				t.Errorf("Failed to create record: %v", err)
+			}
+			ids[i] = record.ID
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("Expected unique IDs, got %d twice", id)
+		}
+		seen[id] = true
+	}
+
+	records, err := repo.GetRecords(context.Background())
+	if err != nil || len(records) != n {
+		t.Errorf("Expected %d records, got %d, %v", n, len(records), err)
+	}
+}
+
+func testConcurrentIncrements(t *testing.T, repo repository.RecordRepositoryInterface) {
+	record := create(t, repo, models.Record{Title: "One Piece", TotalEpisodes: 30, Type: "tv", Status: "watching"})
+
+	const n = 40
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.IncrementProgress(context.Background(), record.ID, 1); err != nil {
+				t.Errorf("Failed to increment progress: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := get(t, repo, record.ID)
+	if got.WatchedEpisodes != 30 {
+		t.Errorf("Expected progress clamped to 30, got %d", got.WatchedEpisodes)
+	}
+	if got.Version != n+1 {
+		t.Errorf("Expected version %d after %d increments, got %d", n+1, n, got.Version)
+	}
+}
+
+func testConcurrentConditionalUpdates(t *testing.T, repo repository.RecordRepositoryInterface) {
+	record := create(t, repo, models.Record{Title: "Steins;Gate", TotalEpisodes: 24, Type: "tv", Status: "watching"})
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			update := record
+			update.WatchedEpisodes = i + 1
+			errs[i] = repo.UpdateRecord(context.Background(), &update)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, repository.ErrVersionMismatch):
+			t.Errorf("Expected ErrVersionMismatch for losing writers, got %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one update at version %d to win, got %d", record.Version, succeeded)
+	}
+	if got := get(t, repo, record.ID); got.Version != 2 {
+		t.Errorf("Expected version 2, got %d", got.Version)
+	}
+}
+
+func compareField(field string, a, b models.Record) int {
+	switch field {
+	case "title":
+		return strings.Compare(a.Title, b.Title)
+	case "total_episodes":
+		return cmp.Compare(a.TotalEpisodes, b.TotalEpisodes)
+	case "watched_episodes":
+		return cmp.Compare(a.WatchedEpisodes, b.WatchedEpisodes)
+	case "type":
+		return strings.Compare(a.Type, b.Type)
+	case "status":
+		return strings.Compare(a.Status, b.Status)
+	default:
+		return cmp.Compare(a.ID, b.ID)
+	}
+}
+
+func ids(records []models.Record) []int {
+	var ids []int
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"golang-watchlist/internal/models"
+	"slices"
+)
+
+// Snapshot calls fn with every record, in the trash or not, in ID order.
+// Records outside the trash have a zero DeletedAt. All records are read
+// in one read-only transaction, so writes made meanwhile are either all
+// seen or not at all. An error from fn stops the read and is returned.
+func (r *RecordRepository) Snapshot(ctx context.Context, fn func(models.TrashedRecord) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return translateError("snapshot", 0, err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + recordColumns + `, deleted_at FROM watch_list ORDER BY id`
+	rows, err := tx.QueryContext(ctx, r.dialect.bind(query))
+	if err != nil {
+		return translateError("snapshot", 0, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record models.TrashedRecord
+		var deletedAt sql.NullTime
+		err := rows.Scan(&record.ID,
+			&record.Title, &record.TotalEpisodes,
+			&record.WatchedEpisodes, &record.Type,
+			&record.Status, &record.Version, &deletedAt)
+		if err != nil {
+			return translateError("snapshot", 0, err)
+		}
+		record.DeletedAt = deletedAt.Time
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return translateError("snapshot", 0, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return translateError("snapshot", 0, err)
+	}
+	return nil
+}
+
+// Snapshot calls fn with every record, in the trash or not, in ID order.
+// Records outside the trash have a zero DeletedAt. The records are copied
+// under one lock, so fn may use the repository.
+func (r *MemoryRecordRepository) Snapshot(ctx context.Context, fn func(models.TrashedRecord) error) error {
+	r.mu.RLock()
+	records := make([]models.TrashedRecord, 0, len(r.records)+len(r.trash))
+	for _, record := range r.records {
+		records = append(records, models.TrashedRecord{Record: record})
+	}
+	for _, record := range r.trash {
+		records = append(records, record)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(records, func(a, b models.TrashedRecord) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
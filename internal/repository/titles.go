@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/models"
+)
+
+// WithDuplicateTitles sets how a record whose title has the same
+// duplicates.Key as another record outside the trash is handled. Under
+// duplicates.Reject, creating such a record, or retitling or restoring a
+// record to such a title, fails with ErrDuplicateTitle. Any other policy
+// allows it, which is also the default.
+func WithDuplicateTitles(policy duplicates.Policy) Option {
+	return func(o *options) {
+		o.duplicateTitles = policy
+	}
+}
+
+// FindByTitle returns the records outside the trash whose title has the
+// same duplicates.Key as title, in ID order. The lookup goes through the
+// indexed title_key column. A title with an empty key matches nothing.
+func (r *RecordRepository) FindByTitle(ctx context.Context, title string) ([]models.Record, error) {
+	if duplicates.Key(title) == "" {
+		return nil, nil
+	}
+	query := `SELECT ` + recordColumns + ` FROM watch_list WHERE title_key = ? AND ` + active + ` ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, r.dialect.bind(query), duplicates.Key(title))
+	if err != nil {
+		return nil, translateError("find by title", 0, err)
+	}
+	defer rows.Close()
+
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, translateError("find by title", 0, err)
+	}
+	return records, nil
+}
+
+// claimTitle returns the title_key and unique_title_key to store with
+// record id under title. Under duplicates.Reject it first checks that no
+// other record outside the trash has the key, and claims the key in
+// unique_title_key, whose unique index stops a concurrent write that
+// passed the same check. Otherwise unique_title_key is left NULL, as it
+// is for a title without letters or digits, whose empty key is never a
+// duplicate.
+func (r *RecordRepository) claimTitle(ctx context.Context, tx *sql.Tx, op string, id int, title string) (string, sql.NullString, error) {
+	key := duplicates.Key(title)
+	if r.options.duplicateTitles != duplicates.Reject || key == "" {
+		return key, sql.NullString{}, nil
+	}
+
+	query := `SELECT id FROM watch_list WHERE title_key = ? AND id <> ? AND ` + active + ` ORDER BY id`
+	var existing int
+	err := tx.QueryRowContext(ctx, r.dialect.bind(query), key, id).Scan(&existing)
+	switch {
+	case err == nil:
+		return "", sql.NullString{}, duplicateTitle(op, id, title, existing)
+	case !errors.Is(err, sql.ErrNoRows):
+		return "", sql.NullString{}, translateError(op, id, err)
+	}
+	return key, sql.NullString{String: key, Valid: true}, nil
+}
+
+// titleColumns returns the assignments that keep title_key and
+// unique_title_key in step when record id is retitled from before to
+// after. A title with the same key needs none.
+func (r *RecordRepository) titleColumns(ctx context.Context, tx *sql.Tx, op string, id int, before, after string) ([]string, []any, error) {
+	if duplicates.Key(before) == duplicates.Key(after) {
+		return nil, nil, nil
+	}
+	key, unique, err := r.claimTitle(ctx, tx, op, id, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{"title_key = ?", "unique_title_key = ?"}, []any{key, unique}, nil
+}
+
+// titleConflict translates the error of a write that stored a
+// unique_title_key. That index is the only unique constraint such a
+// write can violate, so a conflict means another write claimed the
+// title first.
+func titleConflict(op string, id int, title string, err error) error {
+	err = translateError(op, id, err)
+	if errors.Is(err, ErrConflict) {
+		return duplicateTitle(op, id, title, 0)
+	}
+	return err
+}
+
+// FindByTitle returns the records whose title has the same
+// duplicates.Key as title, in ID order.
+func (r *MemoryRecordRepository) FindByTitle(ctx context.Context, title string) ([]models.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return duplicates.Matching(r.sorted(), title), nil
+}
+
+// claimTitle fails under duplicates.Reject when another record has the
+// same title key as title. Callers hold r.mu.
+func (r *MemoryRecordRepository) claimTitle(op string, id int, title string) error {
+	if r.options.duplicateTitles != duplicates.Reject {
+		return nil
+	}
+	for _, record := range duplicates.Matching(r.sorted(), title) {
+		if record.ID != id {
+			return duplicateTitle(op, id, title, record.ID)
+		}
+	}
+	return nil
+}
+
+// retitle checks a rename of record id from before to after. A title
+// with the same key needs no check. Callers hold r.mu.
+func (r *MemoryRecordRepository) retitle(op string, id int, before, after string) error {
+	if duplicates.Key(before) == duplicates.Key(after) {
+		return nil
+	}
+	return r.claimTitle(op, id, after)
+}
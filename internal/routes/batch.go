@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"net/http"
+)
+
+type batchRequest struct {
+	// Atomic defaults to true: the whole batch commits or none of it does.
+	Atomic     *bool                   `json:"atomic"`
+	Operations []batchOperationRequest `json:"operations"`
+}
+
+type batchOperationRequest struct {
+	Op      string         `json:"op"`
+	ID      int            `json:"id"`
+	Version int            `json:"version"`
+	Record  *models.Record `json:"record"`
+}
+
+type batchResponse struct {
+	Atomic  bool          `json:"atomic"`
+	Results []batchResult `json:"results"`
+}
+
+type batchResult struct {
+	Status int            `json:"status"`
+	Record *models.Record `json:"record,omitempty"`
+	Error  *Problem       `json:"error,omitempty"`
+}
+
+// ApplyBatch applies a list of create, update and delete operations in one
+// request and reports the outcome of each. Atomic batches run in a single
+// transaction: when an operation fails nothing is written, and the
+// response takes the failed operation's status.
+func ApplyBatch(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeValidationError(w, r, "Invalid request body")
+			return
+		}
+
+		ops, fieldErrors := parseBatchOperations(req.Operations)
+		if len(fieldErrors) > 0 {
+			writeValidationError(w, r, "Invalid batch", fieldErrors...)
+			return
+		}
+
+		ctx, ok := statusRulesContext(w, r)
+		if !ok {
+			return
+		}
+
+		atomic := req.Atomic == nil || *req.Atomic
+		results, err := repo.ApplyBatch(ctx, ops, atomic)
+		if results == nil && err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+
+		resp := batchResponse{Atomic: atomic, Results: make([]batchResult, len(results))}
+		status := http.StatusOK
+		for i, result := range results {
+			if result.Err != nil {
+				p := repositoryProblem(r, result.Err)
+				resp.Results[i] = batchResult{Status: p.Status, Error: p}
+				if err != nil && !errors.Is(result.Err, repository.ErrBatchAborted) {
+					status = p.Status
+				}
+				continue
+			}
+			resp.Results[i] = batchResult{Status: batchSuccessStatus(ops[i].Op), Record: result.Record}
+		}
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// parseBatchOperations checks the shape of every operation up front, so a
+// malformed batch is rejected before anything is written. Record contents
+// are validated by the repository, per operation.
+func parseBatchOperations(reqs []batchOperationRequest) ([]repository.BatchOperation, []FieldError) {
+	if len(reqs) == 0 || len(reqs) > repository.MaxBatchSize {
+		return nil, []FieldError{{
+			Field:   "operations",
+			Message: fmt.Sprintf("must contain between 1 and %d operations", repository.MaxBatchSize),
+		}}
+	}
+
+	var fieldErrors []FieldError
+	ops := make([]repository.BatchOperation, len(reqs))
+	for i, req := range reqs {
+		field := fmt.Sprintf("operations[%d]", i)
+		switch req.Op {
+		case repository.BatchCreate, repository.BatchUpdate, repository.BatchDelete:
+		default:
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".op", Message: "must be create, update or delete"})
+			continue
+		}
+		if req.Op != repository.BatchCreate && req.ID <= 0 {
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".id", Message: "is required"})
+		}
+		if req.Op != repository.BatchDelete && req.Record == nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".record", Message: "is required"})
+		}
+		if req.Version < 0 {
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".version", Message: "must not be negative"})
+		}
+
+		ops[i] = repository.BatchOperation{Op: req.Op, ID: req.ID, Version: req.Version}
+		if req.Record != nil {
+			ops[i].Record = *req.Record
+		}
+	}
+	return ops, fieldErrors
+}
+
+func batchSuccessStatus(op string) int {
+	switch op {
+	case repository.BatchCreate:
+		return http.StatusCreated
+	case repository.BatchDelete:
+		return http.StatusNoContent
+	}
+	return http.StatusOK
+}
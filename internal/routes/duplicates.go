@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"encoding/json"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// warnDuplicateTitle lists the IDs of existing records with the same
+// title key as the record next creates in X-Duplicate-Of, when policy is
+// duplicates.Warn. Rejecting duplicates is left to the repository, which
+// checks every write inside its transaction.
+func warnDuplicateTitle(repo repository.RecordRepositoryInterface, policy duplicates.Policy, next http.HandlerFunc) http.HandlerFunc {
+	if policy != duplicates.Warn {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := peekBody(w, r)
+		if !ok {
+			return
+		}
+
+		// Malformed bodies are left for next to report.
+		var record models.Record
+		if json.Unmarshal(body, &record) != nil || strings.TrimSpace(record.Title) == "" {
+			next(w, r)
+			return
+		}
+
+		matches, err := repo.FindByTitle(r.Context(), record.Title)
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		if len(matches) > 0 {
+			ids := make([]string, len(matches))
+			for i, match := range matches {
+				ids[i] = strconv.Itoa(match.ID)
+			}
+			w.Header().Set("X-Duplicate-Of", strings.Join(ids, ", "))
+		}
+		next(w, r)
+	}
+}
+
+// GetDuplicates reports pairs of records whose titles look alike, most
+// similar first. The threshold query parameter sets the minimum similarity
+// from 0 to 1.
+func GetDuplicates(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		threshold := duplicates.DefaultThreshold
+		if raw := r.URL.Query().Get("threshold"); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || value <= 0 || value > 1 {
+				writeValidationError(w, r, "Invalid query parameters",
+					FieldError{Field: "threshold", Message: "must be a number greater than 0 and at most 1"})
+				return
+			}
+			threshold = value
+		}
+
+		records, err := repo.GetRecords(r.Context())
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		json.NewEncoder(w).Encode(duplicates.Find(records, threshold))
+	}
+}
+
+type mergeRequest struct {
+	Keep   int `json:"keep"`
+	Remove int `json:"remove"`
+}
+
+// MergeRecords folds the record named by remove into the one named by
+// keep and moves remove to the trash, in one transaction. It returns the
+// kept record.
+func MergeRecords(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req mergeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeValidationError(w, r, "Invalid request body")
+			return
+		}
+
+		var fieldErrors []FieldError
+		if req.Keep <= 0 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "keep", Message: "is required"})
+		}
+		if req.Remove <= 0 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "remove", Message: "is required"})
+		}
+		if req.Keep > 0 && req.Keep == req.Remove {
+			fieldErrors = append(fieldErrors, FieldError{Field: "remove", Message: "must differ from keep"})
+		}
+		if len(fieldErrors) > 0 {
+			writeValidationError(w, r, "Invalid merge", fieldErrors...)
+			return
+		}
+
+		record, err := repo.MergeRecords(r.Context(), req.Keep, req.Remove)
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		setETag(w, record)
+		json.NewEncoder(w).Encode(record)
+	}
+}
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"log"
+	"net/http"
+)
+
+// Stable error codes returned in the "code" field of every problem response.
+const (
+	CodeValidation           = "validation_error"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodePreconditionFailed   = "precondition_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeBatchAborted         = "batch_aborted"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeDuplicateTitle       = "duplicate_title"
+	CodeUnavailable          = "service_unavailable"
+	CodeInternal             = "internal_error"
+)
+
+var errInvalidBody = errors.New("invalid request body")
+
+// writeRepositoryError maps an error returned by the repository onto an
+// HTTP status and error code. Driver messages are logged, never returned.
+func writeRepositoryError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, repositoryProblem(r, err))
+}
+
+func repositoryProblem(r *http.Request, err error) *Problem {
+	var id int
+	var repoErr *repository.Error
+	if errors.As(err, &repoErr) {
+		id = repoErr.ID
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		if id == 0 {
+			return newProblem(r, http.StatusNotFound, CodeNotFound, "Anime record not found")
+		}
+		return newProblem(r, http.StatusNotFound, CodeNotFound,
+			fmt.Sprintf("Anime record with ID %d not found", id))
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return preconditionFailedProblem(r)
+	case errors.Is(err, repository.ErrDuplicateTitle):
+		var dup *repository.DuplicateTitleError
+		if errors.As(err, &dup) && dup.ExistingID != 0 {
+			return newProblem(r, http.StatusConflict, CodeDuplicateTitle,
+				fmt.Sprintf("Anime titled %q already exists with ID %d", dup.Title, dup.ExistingID))
+		}
+		return newProblem(r, http.StatusConflict, CodeDuplicateTitle,
+			"Anime with the same title already exists")
+	case errors.Is(err, repository.ErrConflict):
+		return newProblem(r, http.StatusConflict, CodeConflict,
+			"Anime record conflicts with an existing record")
+	case errors.Is(err, repository.ErrBatchAborted):
+		return newProblem(r, http.StatusFailedDependency, CodeBatchAborted,
+			"Rolled back because another operation in the batch failed")
+	case errors.Is(err, repository.ErrValidation):
+		return recordValidationProblem(r, err)
+	case errors.Is(err, repository.ErrUnavailable):
+		log.Printf("Storage unavailable: %v", err)
+		return newProblem(r, http.StatusServiceUnavailable, CodeUnavailable,
+			"Database is temporarily unavailable")
+	default:
+		log.Printf("Internal error: %v", err)
+		return newProblem(r, http.StatusInternalServerError, CodeInternal,
+			"Internal server error")
+	}
+}
+
+// writeRecordValidationError reports the field errors of a
+// *models.ValidationError, or a generic validation problem for other
+// validation failures such as a value the database rejected.
+func writeRecordValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, recordValidationProblem(r, err))
+}
+
+func recordValidationProblem(r *http.Request, err error) *Problem {
+	var verr *models.ValidationError
+	if errors.As(err, &verr) {
+		return validationProblem(r, "Invalid anime record", verr.Errors...)
+	}
+	return validationProblem(r, "Anime record was rejected by the database")
+}
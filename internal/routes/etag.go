@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errInvalidETag = errors.New("invalid entity tag")
+
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+func setETag(w http.ResponseWriter, record *models.Record) {
+	w.Header().Set("ETag", etag(record.Version))
+}
+
+// ifMatchVersion returns the version named by the If-Match header, or 0
+// when the header is absent or "*". Only a single strong entity tag is
+// supported; weak tags never match under If-Match.
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.HasPrefix(header, "W/") || strings.Contains(header, ",") {
+		return 0, errInvalidETag
+	}
+
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version < 1 || header != etag(version) {
+		return 0, errInvalidETag
+	}
+	return version, nil
+}
+
+// notModified reports whether If-None-Match names the record's current
+// entity tag, using weak comparison.
+func notModified(r *http.Request, record *models.Record) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := etag(record.Version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+func writePreconditionFailed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, preconditionFailedProblem(r))
+}
+
+func preconditionFailedProblem(r *http.Request) *Problem {
+	return newProblem(r, http.StatusPreconditionFailed, CodePreconditionFailed,
+		"If-Match does not name the current version of the anime record")
+}
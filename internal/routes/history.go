@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxRequestIDLength bounds client-supplied request IDs; longer ones are
+// replaced with a generated ID.
+const maxRequestIDLength = 128
+
+// maxActorLength bounds X-Actor, which is stored in every history entry.
+const maxActorLength = 128
+
+// requestContext tags every request with an ID, taken from X-Request-ID
+// or generated, and the actor named by X-Actor, so the changes it makes
+// are attributed in the history. The request ID is echoed in the response.
+//
+// The actor is whatever the client claims; nothing authenticates it, so
+// the history records who a change was attributed to, not who made it.
+// An actor that is too long or contains control characters is rejected.
+func requestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := repository.WithRequestID(r.Context(), id)
+		if actor := r.Header.Get("X-Actor"); actor != "" {
+			if !validActor(actor) {
+				writeValidationError(w, r, "Invalid X-Actor header", FieldError{
+					Field:   "X-Actor",
+					Message: fmt.Sprintf("must be at most %d bytes of text without control characters", maxActorLength),
+				})
+				return
+			}
+			ctx = repository.WithActor(ctx, actor)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validActor(actor string) bool {
+	return len(actor) <= maxActorLength && utf8.ValidString(actor) &&
+		!strings.ContainsFunc(actor, unicode.IsControl)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// GetRecordHistory returns the change timeline of a record, oldest first.
+func GetRecordHistory(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(r)
+		if err != nil {
+			writeValidationError(w, r, "Invalid ID", FieldError{Field: "id", Message: "must be an integer"})
+			return
+		}
+
+		history, err := repo.GetRecordHistory(r.Context(), id)
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		if history == nil {
+			history = []models.HistoryEntry{}
+		}
+		json.NewEncoder(w).Encode(history)
+	}
+}
@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/repository"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+
+	// maxRecordBodySize bounds the body of a record create, which the
+	// idempotency and duplicate checks read whole before the handler.
+	maxRecordBodySize = 1 << 20
+)
+
+// replayedHeaders are the response headers stored with an idempotent
+// response and sent again on replay.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location", "X-Duplicate-Of"}
+
+// idempotent makes next safe to retry with an Idempotency-Key header. The
+// first request with a key runs and its response is stored for ttl;
+// retries with the same body get the stored response back, while reusing
+// the key for a different request is rejected. Server errors and panics
+// are not stored, so the request can be retried, and a key whose request
+// died without settling it is freed once its lease runs out.
+func idempotent(store repository.IdempotencyStore, ttl, lease time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeValidationError(w, r, "Invalid Idempotency-Key", FieldError{
+				Field:   "Idempotency-Key",
+				Message: fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength),
+			})
+			return
+		}
+
+		body, ok := peekBody(w, r)
+		if !ok {
+			return
+		}
+
+		sum := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+		hash := hex.EncodeToString(sum[:])
+
+		stored, err := store.Reserve(r.Context(), key, hash, time.Now().Add(min(lease, ttl)))
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		if stored != nil {
+			replayIdempotent(w, r, stored, hash)
+			return
+		}
+
+		// The key must be settled even if the client has gone away, and
+		// released if the handler panics so a retry runs it again.
+		ctx := context.WithoutCancel(r.Context())
+		defer func() {
+			if p := recover(); p != nil {
+				if err := store.Release(ctx, key); err != nil {
+					log.Printf("Failed to release Idempotency-Key %q: %v", key, err)
+				}
+				panic(p)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		if rec.status >= http.StatusInternalServerError {
+			err = store.Release(ctx, key)
+		} else {
+			header := map[string]string{}
+			for _, name := range replayedHeaders {
+				if value := rec.Header().Get(name); value != "" {
+					header[name] = value
+				}
+			}
+			err = store.Complete(ctx, key, rec.status, header, rec.body.Bytes(), time.Now().Add(ttl))
+		}
+		if err != nil {
+			log.Printf("Failed to store response for Idempotency-Key %q: %v", key, err)
+		}
+	}
+}
+
+// peekBody reads the whole body of r, up to maxRecordBodySize, and puts
+// it back for the next handler. It reports an oversized or unreadable body
+// and returns false.
+func peekBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRecordBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, CodeValidation,
+			fmt.Sprintf("Request bodies are limited to %d bytes", tooLarge.Limit))
+		return nil, false
+	}
+	if err != nil {
+		writeValidationError(w, r, "Invalid request body")
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+func replayIdempotent(w http.ResponseWriter, r *http.Request, stored *repository.IdempotentResponse, hash string) {
+	switch {
+	case stored.RequestHash != hash:
+		writeError(w, r, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused,
+			"Idempotency-Key was already used with a different request")
+	case stored.Status == 0:
+		writeError(w, r, http.StatusConflict, CodeConflict,
+			"A request with this Idempotency-Key is still in progress")
+	default:
+		for name, value := range stored.Header {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.Status)
+		w.Write(stored.Body)
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
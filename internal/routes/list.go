@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var listParams = []string{"status", "type", "title", "sort", "order", "limit", "cursor", "as_of"}
+
+func hasListParams(query url.Values) bool {
+	for _, name := range listParams {
+		if query.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseListOptions(query url.Values) (repository.ListOptions, []FieldError) {
+	opts := repository.ListOptions{
+		Status:        strings.ToLower(strings.TrimSpace(query.Get("status"))),
+		Type:          strings.ToLower(strings.TrimSpace(query.Get("type"))),
+		TitleContains: query.Get("title"),
+		SortBy:        query.Get("sort"),
+		Cursor:        query.Get("cursor"),
+	}
+
+	var fieldErrors []FieldError
+	if opts.Status != "" && !slices.Contains(models.Statuses, opts.Status) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "status", Message: "must be one of " + strings.Join(models.Statuses, ", ")})
+	}
+	if opts.Type != "" && !slices.Contains(models.Types, opts.Type) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "type", Message: "must be one of " + strings.Join(models.Types, ", ")})
+	}
+	if opts.SortBy != "" {
+		if _, ok := repository.SortableFields[opts.SortBy]; !ok {
+			fieldErrors = append(fieldErrors, FieldError{Field: "sort", Message: fmt.Sprintf("cannot sort by %q", opts.SortBy)})
+		}
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		fieldErrors = append(fieldErrors, FieldError{Field: "order", Message: "must be asc or desc"})
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > repository.MaxListLimit {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   "limit",
+				Message: fmt.Sprintf("must be an integer between 1 and %d", repository.MaxListLimit),
+			})
+		}
+		opts.Limit = limit
+	}
+
+	if raw := query.Get("as_of"); raw != "" {
+		asOf, err := parseAsOf(raw)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   "as_of",
+				Message: "must be an RFC 3339 timestamp or a YYYY-MM-DD date",
+			})
+		}
+		opts.AsOf = asOf
+	}
+
+	return opts, fieldErrors
+}
+
+// parseAsOf accepts an RFC 3339 timestamp, or a date meaning midnight UTC
+// at the start of that day.
+func parseAsOf(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, raw)
+}
+
+// nextPageURL rebuilds the request URL with the cursor replaced, for the
+// Link header.
+func nextPageURL(u *url.URL, cursor string) string {
+	query := u.Query()
+	query.Set("cursor", cursor)
+	next := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return next.String()
+}
@@ -0,0 +1,207 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+var errPatchTestFailed = errors.New("json patch test operation failed")
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+func PatchRecord(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(r)
+		if err != nil {
+			writeValidationError(w, r, "Invalid ID", FieldError{Field: "id", Message: "must be an integer"})
+			return
+		}
+
+		var patch models.RecordPatch
+		var fieldErrors []FieldError
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case mergePatchContentType, "application/json", "":
+			patch, fieldErrors, err = decodeMergePatch(r.Body)
+		case jsonPatchContentType:
+			patch, fieldErrors, err = decodeJSONPatch(r.Context(), repo, id, r.Body)
+		default:
+			w.Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+			writeError(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+				fmt.Sprintf("Unsupported patch format %q", mediaType))
+			return
+		}
+
+		switch {
+		case errors.Is(err, errPatchTestFailed):
+			writeError(w, r, http.StatusConflict, CodeConflict, err.Error())
+			return
+		case errors.Is(err, errInvalidBody):
+			writeValidationError(w, r, "Invalid request body")
+			return
+		case err != nil:
+			writeRepositoryError(w, r, err)
+			return
+		}
+
+		patch.Normalize()
+		var verr *models.ValidationError
+		if errors.As(patch.Validate(), &verr) {
+			fieldErrors = append(fieldErrors, verr.Errors...)
+		}
+		if len(fieldErrors) > 0 {
+			writeValidationError(w, r, "Invalid patch document", fieldErrors...)
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writePreconditionFailed(w, r)
+			return
+		}
+		if version != 0 {
+			patch.Version = version
+		}
+
+		ctx, ok := statusRulesContext(w, r)
+		if !ok {
+			return
+		}
+
+		record, err := repo.PatchRecord(ctx, id, patch)
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		setETag(w, record)
+		json.NewEncoder(w).Encode(record)
+	}
+}
+
+// decodeMergePatch parses an RFC 7396 merge patch. Every column of
+// watch_list is required, so a null member is rejected rather than
+// treated as a removal.
+func decodeMergePatch(body io.Reader) (models.RecordPatch, []FieldError, error) {
+	var patch models.RecordPatch
+	var members map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&members); err != nil {
+		return patch, nil, errInvalidBody
+	}
+
+	var fieldErrors []FieldError
+	for field, value := range members {
+		if fe := setPatchField(&patch, field, value); fe != nil {
+			fieldErrors = append(fieldErrors, *fe)
+		}
+	}
+	return patch, fieldErrors, nil
+}
+
+// decodeJSONPatch parses an RFC 6902 patch. Only add, replace and test on
+// top-level members are meaningful for a record; test compares against
+// the stored record with the preceding operations applied. A patch with a
+// test is conditional on the version it was tested against, so a write
+// landing in between fails instead of slipping past the test.
+func decodeJSONPatch(ctx context.Context, repo repository.RecordRepositoryInterface, id int, body io.Reader) (models.RecordPatch, []FieldError, error) {
+	var patch models.RecordPatch
+	var ops []jsonPatchOperation
+	if err := json.NewDecoder(body).Decode(&ops); err != nil {
+		return patch, nil, errInvalidBody
+	}
+
+	var current *models.Record
+	var fieldErrors []FieldError
+	for _, op := range ops {
+		field := strings.TrimPrefix(op.Path, "/")
+		if field == op.Path || strings.Contains(field, "/") {
+			fieldErrors = append(fieldErrors, FieldError{Field: op.Path, Message: "must point to a top-level member"})
+			continue
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if fe := setPatchField(&patch, field, op.Value); fe != nil {
+				fieldErrors = append(fieldErrors, *fe)
+			}
+		case "test":
+			if current == nil {
+				record, err := repo.GetRecordByID(ctx, id)
+				if err != nil {
+					return patch, nil, err
+				}
+				current = record
+				patch.Version = record.Version
+			}
+			doc := *current
+			patch.Apply(&doc)
+			if !jsonFieldEquals(doc, field, op.Value) {
+				return patch, nil, fmt.Errorf("%w: %s does not match", errPatchTestFailed, op.Path)
+			}
+		default:
+			fieldErrors = append(fieldErrors, FieldError{Field: op.Path, Message: fmt.Sprintf("unsupported operation %q", op.Op)})
+		}
+	}
+	return patch, fieldErrors, nil
+}
+
+func setPatchField(patch *models.RecordPatch, field string, value json.RawMessage) *FieldError {
+	if len(value) == 0 || string(value) == "null" {
+		return &FieldError{Field: field, Message: "cannot be null"}
+	}
+
+	var target any
+	switch field {
+	case "title":
+		target = &patch.Title
+	case "total_episodes":
+		target = &patch.TotalEpisodes
+	case "watched_episodes":
+		target = &patch.WatchedEpisodes
+	case "type":
+		target = &patch.Type
+	case "status":
+		target = &patch.Status
+	default:
+		return &FieldError{Field: field, Message: "cannot be patched"}
+	}
+
+	if err := json.Unmarshal(value, target); err != nil {
+		return &FieldError{Field: field, Message: "has an invalid type"}
+	}
+	return nil
+}
+
+func jsonFieldEquals(record models.Record, field string, value json.RawMessage) bool {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return false
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return false
+	}
+	var expected any
+	if err := json.Unmarshal(value, &expected); err != nil {
+		return false
+	}
+	actual, ok := doc[field]
+	return ok && reflect.DeepEqual(actual, expected)
+}
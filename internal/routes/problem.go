@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"encoding/json"
+	"golang-watchlist/internal/models"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Code carries the stable
+// error code and Errors lists field-level validation failures.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError = models.FieldError
+
+func newProblem(r *http.Request, status int, code, detail string) *Problem {
+	return &Problem{
+		Type:     "/problems/" + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+	}
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblem(w, newProblem(r, status, code, detail))
+}
+
+func writeValidationError(w http.ResponseWriter, r *http.Request, detail string, fieldErrors ...FieldError) {
+	writeProblem(w, validationProblem(r, detail, fieldErrors...))
+}
+
+func validationProblem(r *http.Request, detail string, fieldErrors ...FieldError) *Problem {
+	p := newProblem(r, http.StatusBadRequest, CodeValidation, detail)
+	p.Errors = fieldErrors
+	return p
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"golang-watchlist/internal/repository"
+	"io"
+	"net/http"
+)
+
+type progressRequest struct {
+	Delta *int `json:"delta"`
+}
+
+// IncrementProgress adds delta episodes (default 1, negative to rewind) to
+// a record's progress atomically and returns the updated record.
+func IncrementProgress(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(r)
+		if err != nil {
+			writeValidationError(w, r, "Invalid ID", FieldError{Field: "id", Message: "must be an integer"})
+			return
+		}
+
+		var req progressRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeValidationError(w, r, "Invalid request body")
+			return
+		}
+		delta := 1
+		if req.Delta != nil {
+			delta = *req.Delta
+		}
+
+		ctx, ok := statusRulesContext(w, r)
+		if !ok {
+			return
+		}
+
+		record, err := repo.IncrementProgress(ctx, id, delta)
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		setETag(w, record)
+		json.NewEncoder(w).Encode(record)
+	}
+}
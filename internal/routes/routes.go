@@ -1,41 +1,125 @@
 package routes
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/duplicates"
 	"golang-watchlist/internal/models"
 	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/rules"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func HandleRecordRoutes(router *mux.Router, db *sql.DB) {
-	repo := repository.NewRecordRepository(db)
-	router.HandleFunc("/watchlist", CreateRecord(repo)).Methods(http.MethodPost)
+	repo := repository.NewRecordRepository(db, rules.WithEngine(rules.DefaultEngine()))
+	RegisterRecordRoutes(router, repo, WithIdempotency(repository.NewIdempotencyStore(db), repository.DefaultIdempotencyTTL))
+}
+
+type routeConfig struct {
+	idempotencyStore repository.IdempotencyStore
+	idempotencyTTL   time.Duration
+	idempotencyLease time.Duration
+	duplicatePolicy  duplicates.Policy
+}
+
+// Option configures RegisterRecordRoutes.
+type Option func(*routeConfig)
+
+// WithIdempotency stores Idempotency-Keys in store for ttl. Without it,
+// keys are kept in process memory for repository.DefaultIdempotencyTTL.
+func WithIdempotency(store repository.IdempotencyStore, ttl time.Duration) Option {
+	return func(c *routeConfig) {
+		c.idempotencyStore = store
+		c.idempotencyTTL = ttl
+	}
+}
+
+// WithIdempotencyLease sets how long a request may run while holding its
+// Idempotency-Key, repository.DefaultIdempotencyLease by default. A retry
+// arriving after the lease has run out takes the key over and runs again,
+// so the lease must outlast the slowest request.
+func WithIdempotencyLease(lease time.Duration) Option {
+	return func(c *routeConfig) {
+		c.idempotencyLease = lease
+	}
+}
+
+// WithDuplicatePolicy sets how a new record whose title matches an
+// existing one is reported. Under duplicates.Warn, the default, the
+// matches are listed in X-Duplicate-Of. Duplicates are rejected by a
+// repository created with repository.WithDuplicateTitles, whose
+// ErrDuplicateTitle is reported as 409 duplicate_title.
+func WithDuplicatePolicy(policy duplicates.Policy) Option {
+	return func(c *routeConfig) {
+		c.duplicatePolicy = policy
+	}
+}
+
+func RegisterRecordRoutes(router *mux.Router, repo repository.RecordRepositoryInterface, opts ...Option) {
+	cfg := routeConfig{
+		idempotencyTTL:   repository.DefaultIdempotencyTTL,
+		idempotencyLease: repository.DefaultIdempotencyLease,
+		duplicatePolicy:  duplicates.Warn,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.idempotencyStore == nil {
+		cfg.idempotencyStore = repository.NewMemoryIdempotencyStore()
+	}
+
+	router.Use(requestContext)
+	create := warnDuplicateTitle(repo, cfg.duplicatePolicy, CreateRecord(repo))
+	router.HandleFunc("/watchlist", idempotent(cfg.idempotencyStore, cfg.idempotencyTTL, cfg.idempotencyLease, create)).Methods(http.MethodPost)
 	router.HandleFunc("/watchlist", GetRecords(repo)).Methods(http.MethodGet)
+	router.HandleFunc("/watchlist/trash", GetTrash(repo)).Methods(http.MethodGet)
+	router.HandleFunc("/watchlist/batch", ApplyBatch(repo)).Methods(http.MethodPost)
+	router.HandleFunc("/watchlist/duplicates", GetDuplicates(repo)).Methods(http.MethodGet)
+	router.HandleFunc("/watchlist/merge", MergeRecords(repo)).Methods(http.MethodPost)
+	router.HandleFunc("/watchlist/export.csv", ExportCSV(repo)).Methods(http.MethodGet)
+	router.HandleFunc("/watchlist/export.xml", ExportMAL(repo)).Methods(http.MethodGet)
+	router.HandleFunc("/watchlist/export.ndjson", ExportNDJSON(repo)).Methods(http.MethodGet)
+	router.HandleFunc("/watchlist/import", ImportRecords(repo)).Methods(http.MethodPost)
+	router.HandleFunc("/watchlist/{id}", GetRecordByID(repo)).Methods(http.MethodGet)
 	router.HandleFunc("/watchlist/{id}", UpdateRecord(repo)).Methods(http.MethodPut)
+	router.HandleFunc("/watchlist/{id}", PatchRecord(repo)).Methods(http.MethodPatch)
 	router.HandleFunc("/watchlist/{id}", DeleteRecord(repo)).Methods(http.MethodDelete)
+	router.HandleFunc("/watchlist/{id}/progress", IncrementProgress(repo)).Methods(http.MethodPost)
+	router.HandleFunc("/watchlist/{id}/restore", RestoreRecord(repo)).Methods(http.MethodPost)
+	router.HandleFunc("/watchlist/{id}/history", GetRecordHistory(repo)).Methods(http.MethodGet)
 }
 
 func CreateRecord(repo repository.RecordRepositoryInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var record models.Record
 		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeValidationError(w, r, "Invalid request body")
 			return
 		}
 
-		if record.Title == "" || record.Status == "" {
-			http.Error(w, "Title and Status are required", http.StatusBadRequest)
+		record.Normalize()
+		if err := record.Validate(); err != nil {
+			writeRecordValidationError(w, r, err)
 			return
 		}
 
-		if err := repo.CreateRecord(r.Context(), &record); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		ctx, ok := statusRulesContext(w, r)
+		if !ok {
 			return
 		}
+
+		if err := repo.CreateRecord(ctx, &record); err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		setETag(w, &record)
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(record)
 	}
@@ -43,55 +127,155 @@ func CreateRecord(repo repository.RecordRepositoryInterface) http.HandlerFunc {
 
 func GetRecords(repo repository.RecordRepositoryInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		records, err := repo.GetRecords(r.Context())
+		if !hasListParams(r.URL.Query()) {
+			records, err := repo.GetRecords(r.Context())
+			if err != nil {
+				writeRepositoryError(w, r, err)
+				return
+			}
+			json.NewEncoder(w).Encode(records)
+			return
+		}
+
+		opts, fieldErrors := parseListOptions(r.URL.Query())
+		if len(fieldErrors) > 0 {
+			writeValidationError(w, r, "Invalid query parameters", fieldErrors...)
+			return
+		}
+
+		page, err := repo.ListRecords(r.Context(), opts)
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			writeValidationError(w, r, "Invalid query parameters",
+				FieldError{Field: "cursor", Message: "is not valid for this query"})
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeRepositoryError(w, r, err)
 			return
 		}
-		json.NewEncoder(w).Encode(records)
+
+		if page.NextCursor != "" {
+			w.Header().Set("X-Next-Cursor", page.NextCursor)
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextPageURL(r.URL, page.NextCursor)))
+		}
+		if page.Records == nil {
+			page.Records = []models.Record{}
+		}
+		json.NewEncoder(w).Encode(page.Records)
+	}
+}
+
+func GetRecordByID(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(r)
+		if err != nil {
+			writeValidationError(w, r, "Invalid ID", FieldError{Field: "id", Message: "must be an integer"})
+			return
+		}
+
+		record, err := repo.GetRecordByID(r.Context(), id)
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+
+		setETag(w, record)
+		if notModified(r, record) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		json.NewEncoder(w).Encode(record)
 	}
 }
 
 func UpdateRecord(repo repository.RecordRepositoryInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		id, err := parseID(r)
 		if err != nil {
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			writeValidationError(w, r, "Invalid ID", FieldError{Field: "id", Message: "must be an integer"})
 			return
 		}
 
 		var record models.Record
 		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeValidationError(w, r, "Invalid request body")
 			return
 		}
 
-		if record.Title == "" || record.Status == "" {
-			http.Error(w, "Title and Status are required", http.StatusBadRequest)
+		record.Normalize()
+		if err := record.Validate(); err != nil {
+			writeRecordValidationError(w, r, err)
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writePreconditionFailed(w, r)
+			return
+		}
+
+		ctx, ok := statusRulesContext(w, r)
+		if !ok {
 			return
 		}
 
 		record.ID = id
-		if err := repo.UpdateRecord(r.Context(), &record); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		record.Version = version
+		if err := repo.UpdateRecord(ctx, &record); err != nil {
+			writeRepositoryError(w, r, err)
 			return
 		}
+		setETag(w, &record)
 		json.NewEncoder(w).Encode(record)
 	}
 }
 
 func DeleteRecord(repo repository.RecordRepositoryInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		id, err := parseID(r)
+		if err != nil {
+			writeValidationError(w, r, "Invalid ID", FieldError{Field: "id", Message: "must be an integer"})
+			return
+		}
+
+		version, err := ifMatchVersion(r)
 		if err != nil {
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			writePreconditionFailed(w, r)
 			return
 		}
 
-		if err := repo.DeleteRecord(r.Context(), id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if version > 0 {
+			err = repo.DeleteRecordVersion(r.Context(), id, version)
+		} else {
+			err = repo.DeleteRecord(r.Context(), id)
+		}
+		if err != nil {
+			writeRepositoryError(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+func parseID(r *http.Request) (int, error) {
+	return strconv.Atoi(mux.Vars(r)["id"])
+}
+
+// statusRulesContext honours the auto_status query parameter, which lets a
+// single write opt out of automatic status transitions.
+func statusRulesContext(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	raw := r.URL.Query().Get("auto_status")
+	if raw == "" {
+		return r.Context(), true
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		writeValidationError(w, r, "Invalid query parameters",
+			FieldError{Field: "auto_status", Message: "must be true or false"})
+		return nil, false
+	}
+	if !enabled {
+		return rules.Disable(r.Context()), true
+	}
+	return r.Context(), true
+}
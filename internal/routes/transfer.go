@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/transfer"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	csvContentType    = "text/csv"
+	xmlContentType    = "application/xml"
+	ndjsonContentType = "application/x-ndjson"
+
+	// maxImportSize bounds the body of an import request.
+	maxImportSize = 16 << 20
+)
+
+// ExportCSV streams every record as CSV, a page at a time.
+func ExportCSV(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := transfer.NewCSVWriter(w)
+		exportFile(w, r, repo, csvContentType+"; charset=utf-8", "watchlist.csv", out.Write, out.Close)
+	}
+}
+
+// ExportNDJSON streams every record as newline-delimited JSON, a page at
+// a time, for backups and migrations of lists of any size.
+func ExportNDJSON(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := transfer.NewNDJSONWriter(w)
+		exportFile(w, r, repo, ndjsonContentType, "watchlist.ndjson", out.Write, out.Close)
+	}
+}
+
+// ExportMAL sends every record as a MyAnimeList XML export. The export
+// header counts the records by status, so the list is read in one go.
+func ExportMAL(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, err := repo.GetRecords(r.Context())
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", xmlContentType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="watchlist.xml"`)
+		if err := transfer.WriteMAL(w, records); err != nil {
+			log.Printf("Export of watchlist.xml stopped: %v", err)
+		}
+	}
+}
+
+// exportFile pages through the records, handing each page to write. The
+// status and headers are sent with the first page, so a repository error
+// before then is still reported as a problem; later errors can only cut
+// the download short.
+func exportFile(w http.ResponseWriter, r *http.Request, repo repository.RecordRepositoryInterface,
+	contentType, filename string, write func([]models.Record) error, finish func() error) {
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		}
+	}
+
+	err := transfer.EachPage(r.Context(), repo, func(records []models.Record) error {
+		start()
+		if err := write(records); err != nil {
+			return err
+		}
+		if err := http.NewResponseController(w).Flush(); !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+	if err != nil && !started {
+		writeRepositoryError(w, r, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Export of %s stopped: %v", filename, err)
+		return
+	}
+	start()
+	if err := finish(); err != nil {
+		log.Printf("Export of %s stopped: %v", filename, err)
+	}
+}
+
+// ImportRecords creates and updates records from an uploaded CSV file,
+// MyAnimeList XML export or NDJSON stream and reports a summary of
+// created, updated and skipped rows. Each row is validated and written on
+// its own; dry_run=true reports what would happen without writing
+// anything.
+func ImportRecords(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var fieldErrors []FieldError
+
+		dryRun := false
+		if raw := query.Get("dry_run"); raw != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(raw); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: "dry_run", Message: "must be true or false"})
+			}
+		}
+
+		mapping := map[string]string{}
+		for _, pair := range query["map"] {
+			header, field, ok := strings.Cut(pair, ":")
+			if !ok || strings.TrimSpace(header) == "" || !transfer.ValidField(field) {
+				fieldErrors = append(fieldErrors, FieldError{
+					Field:   "map",
+					Message: fmt.Sprintf("%q must look like header:field with field one of %s", pair, strings.Join(transfer.CSVColumns, ", ")),
+				})
+				continue
+			}
+			mapping[header] = field
+		}
+		if len(fieldErrors) > 0 {
+			writeValidationError(w, r, "Invalid query parameters", fieldErrors...)
+			return
+		}
+
+		ctx, ok := statusRulesContext(w, r)
+		if !ok {
+			return
+		}
+
+		// NDJSON is read a line at a time as it is imported, so only the
+		// other formats, which are parsed whole first, are size limited.
+		body := http.MaxBytesReader(w, r.Body, maxImportSize)
+		var rows transfer.RowReader
+		var err error
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case csvContentType, "":
+			rows, err = sliceRows(transfer.ReadCSV(body, mapping))
+		case xmlContentType, "text/xml":
+			rows, err = sliceRows(transfer.ReadMAL(body))
+		case ndjsonContentType:
+			rows = transfer.NewNDJSONReader(r.Body)
+		default:
+			w.Header().Set("Accept-Post", strings.Join([]string{csvContentType, xmlContentType, ndjsonContentType}, ", "))
+			writeError(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+				fmt.Sprintf("Unsupported import format %q", mediaType))
+			return
+		}
+		if err != nil {
+			writeImportFileError(w, r, err)
+			return
+		}
+
+		summary, err := transfer.ImportFrom(ctx, repo, rows, dryRun)
+		var readErr *transfer.ReadError
+		if errors.As(err, &readErr) {
+			writeImportFileError(w, r, readErr.Err)
+			return
+		}
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		json.NewEncoder(w).Encode(summary)
+	}
+}
+
+func sliceRows(rows []transfer.Row, err error) (transfer.RowReader, error) {
+	if err != nil {
+		return nil, err
+	}
+	return transfer.NewRowReader(rows), nil
+}
+
+func writeImportFileError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, CodeValidation,
+			fmt.Sprintf("Import files are limited to %d bytes", tooLarge.Limit))
+		return
+	}
+	writeValidationError(w, r, "Invalid import file: "+err.Error())
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"encoding/json"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"net/http"
+)
+
+// GetTrash lists soft-deleted records, most recently deleted first.
+func GetTrash(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, err := repo.ListTrash(r.Context())
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		if records == nil {
+			records = []models.TrashedRecord{}
+		}
+		json.NewEncoder(w).Encode(records)
+	}
+}
+
+// RestoreRecord takes a record out of the trash and returns it.
+func RestoreRecord(repo repository.RecordRepositoryInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseID(r)
+		if err != nil {
+			writeValidationError(w, r, "Invalid ID", FieldError{Field: "id", Message: "must be an integer"})
+			return
+		}
+
+		record, err := repo.RestoreRecord(r.Context(), id)
+		if err != nil {
+			writeRepositoryError(w, r, err)
+			return
+		}
+		setETag(w, record)
+		json.NewEncoder(w).Encode(record)
+	}
+}
@@ -0,0 +1,36 @@
+package rules
+
+import (
+	"context"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+)
+
+type disabledKey struct{}
+
+// Disable returns a context under which writes are stored exactly as
+// given, even on a repository configured WithEngine.
+func Disable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disabledKey{}, true)
+}
+
+func disabled(ctx context.Context) bool {
+	off, _ := ctx.Value(disabledKey{}).(bool)
+	return off
+}
+
+// WithEngine configures a repository to apply engine to every record it
+// creates, updates, patches or increments, batches included. The engine
+// runs inside the write transaction, on the locked row and after any patch
+// or increment, so a derived status is stored atomically with the change
+// that caused it.
+func WithEngine(engine *Engine) repository.Option {
+	if !engine.Enabled() {
+		return repository.WithBeforeWrite(nil)
+	}
+	return repository.WithBeforeWrite(func(ctx context.Context, record *models.Record) {
+		if !disabled(ctx) {
+			engine.Apply(record)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package rules
+
+import (
+	"fmt"
+	"golang-watchlist/internal/models"
+	"strings"
+)
+
+// Rule derives a record's status from its progress.
+type Rule struct {
+	Name  string
+	Apply func(record *models.Record)
+}
+
+var (
+	// CompleteWhenFinished marks a record completed once every episode is
+	// watched. Records with an unknown total (0) are left alone.
+	CompleteWhenFinished = Rule{
+		Name: "complete",
+		Apply: func(record *models.Record) {
+			if record.TotalEpisodes > 0 && record.WatchedEpisodes >= record.TotalEpisodes {
+				record.Status = models.StatusCompleted
+			}
+		},
+	}
+
+	// StartWhenWatched moves a planned record to watching once progress
+	// is recorded.
+	StartWhenWatched = Rule{
+		Name: "start",
+		Apply: func(record *models.Record) {
+			if record.Status == models.StatusPlanning && record.WatchedEpisodes > 0 {
+				record.Status = models.StatusWatching
+			}
+		},
+	}
+
+	// ReopenWhenBehind moves a completed record back to watching when its
+	// total grows past the watched count, e.g. when a new season airs.
+	ReopenWhenBehind = Rule{
+		Name: "reopen",
+		Apply: func(record *models.Record) {
+			if record.Status == models.StatusCompleted && record.TotalEpisodes > 0 &&
+				record.WatchedEpisodes < record.TotalEpisodes {
+				record.Status = models.StatusWatching
+			}
+		},
+	}
+)
+
+var available = []Rule{CompleteWhenFinished, StartWhenWatched, ReopenWhenBehind}
+
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// DefaultSpec is the rule list DefaultEngine runs, as ParseEngine reads
+// it.
+const DefaultSpec = "complete,start"
+
+func DefaultEngine() *Engine {
+	return NewEngine(CompleteWhenFinished, StartWhenWatched)
+}
+
+// ParseEngine builds an engine from a comma-separated list of rule names.
+// "none" or an empty string disables every rule.
+func ParseEngine(spec string) (*Engine, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "none" {
+		return NewEngine(), nil
+	}
+
+	var rules []Rule
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		rule, ok := lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown status rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+	return NewEngine(rules...), nil
+}
+
+func lookup(name string) (Rule, bool) {
+	for _, rule := range available {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Apply runs every rule in order.
+func (e *Engine) Apply(record *models.Record) {
+	for _, rule := range e.rules {
+		rule.Apply(record)
+	}
+}
+
+func (e *Engine) Enabled() bool {
+	return e != nil && len(e.rules) > 0
+}
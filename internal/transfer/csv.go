@@ -0,0 +1,232 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVColumns are the columns of an exported CSV file, in order.
+var CSVColumns = []string{FieldID, FieldTitle, FieldTotalEpisodes, FieldWatchedEpisodes, FieldType, FieldStatus, FieldVersion}
+
+// csvAliases are other header names spreadsheets commonly use for each
+// field. Headers are compared after normalizeHeader.
+var csvAliases = map[string]string{
+	"name":     FieldTitle,
+	"anime":    FieldTitle,
+	"series":   FieldTitle,
+	"episodes": FieldTotalEpisodes,
+	"total":    FieldTotalEpisodes,
+	"watched":  FieldWatchedEpisodes,
+	"progress": FieldWatchedEpisodes,
+}
+
+// ErrNoTitleColumn is returned for CSV files with neither a title nor an
+// id column, whose rows could not be matched or created.
+var ErrNoTitleColumn = errors.New("csv header has no title or id column")
+
+func normalizeHeader(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// ValidField reports whether name is a field an import can map a column to.
+func ValidField(name string) bool {
+	for _, field := range CSVColumns {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadCSV reads import rows from CSV with a header line. Columns are
+// matched to fields by name, by a common alias, or through mapping, which
+// maps header names to field names and takes precedence. Unknown columns
+// are ignored.
+func ReadCSV(r io.Reader, mapping map[string]string) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrNoTitleColumn
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]string{}
+	for name, field := range mapping {
+		overrides[normalizeHeader(name)] = field
+	}
+	columns := make([]string, len(header))
+	mapped := map[string]bool{}
+	for i, name := range header {
+		name = normalizeHeader(strings.TrimPrefix(name, "\ufeff"))
+		field, ok := overrides[name]
+		if !ok {
+			field, ok = csvAliases[name]
+		}
+		if !ok && ValidField(name) {
+			field = name
+		}
+		if field != "" && !mapped[field] {
+			columns[i] = field
+			mapped[field] = true
+		}
+	}
+	if !mapped[FieldTitle] && !mapped[FieldID] {
+		return nil, ErrNoTitleColumn
+	}
+
+	var rows []Row
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blank(values) {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := Row{Line: line, Fields: map[string]bool{}}
+		for i, value := range values {
+			if i >= len(columns) || columns[i] == "" {
+				continue
+			}
+			setField(&row, columns[i], unescapeFormula(strings.TrimSpace(value)))
+		}
+		rows = append(rows, row)
+	}
+}
+
+func blank(values []string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// setField stores one cell on the row. Empty cells are treated as absent,
+// so they keep the stored value when the row updates a record.
+func setField(row *Row, field, value string) {
+	if value == "" {
+		return
+	}
+	switch field {
+	case FieldTitle:
+		row.Record.Title = value
+	case FieldType:
+		row.Record.Type = value
+	case FieldStatus:
+		row.Record.Status = value
+	default:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			row.Errors = append(row.Errors, models.FieldError{Field: field, Message: "must be an integer"})
+			return
+		}
+		switch field {
+		case FieldID:
+			row.Record.ID = n
+		case FieldTotalEpisodes:
+			row.Record.TotalEpisodes = n
+		case FieldWatchedEpisodes:
+			row.Record.WatchedEpisodes = n
+		case FieldVersion:
+			row.Record.Version = n
+		}
+	}
+	row.Fields[field] = true
+}
+
+// formulaPrefixes are the leading characters that make spreadsheets
+// evaluate a cell as a formula.
+const formulaPrefixes = "=+-@"
+
+// escapeFormula prefixes a cell that would be evaluated as a formula with
+// a quote, so a title like "=HYPERLINK(...)" opens as text.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeFormula undoes escapeFormula, so exported files import back
+// unchanged. Only CSV cells are escaped; other formats keep a leading
+// apostrophe.
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// CSVWriter writes records as CSV under a CSVColumns header. Text cells
+// that start like a formula are escaped with a leading quote.
+type CSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes records and flushes them to the underlying writer. The
+// header is written before the first records.
+func (c *CSVWriter) Write(records []models.Record) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	for _, record := range records {
+		err := c.w.Write([]string{
+			strconv.Itoa(record.ID),
+			escapeFormula(record.Title),
+			strconv.Itoa(record.TotalEpisodes),
+			strconv.Itoa(record.WatchedEpisodes),
+			escapeFormula(record.Type),
+			escapeFormula(record.Status),
+			strconv.Itoa(record.Version),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Close writes the header if no records were written, so an empty list
+// still exports as a valid file.
+func (c *CSVWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	if err := c.w.Write(CSVColumns); err != nil {
+		return fmt.Errorf("write csv header: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package transfer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// malStatuses maps the statuses of a MyAnimeList export to ours. Older
+// exports use the numeric codes.
+var malStatuses = map[string]string{
+	"watching":      models.StatusWatching,
+	"1":             models.StatusWatching,
+	"completed":     models.StatusCompleted,
+	"2":             models.StatusCompleted,
+	"on-hold":       models.StatusOnHold,
+	"3":             models.StatusOnHold,
+	"dropped":       models.StatusDropped,
+	"4":             models.StatusDropped,
+	"plan to watch": models.StatusPlanning,
+	"6":             models.StatusPlanning,
+}
+
+// malTypes maps the series types of a MyAnimeList export to ours. Types
+// without an equivalent, such as Music, are kept as given and fail
+// validation.
+var malTypes = map[string]string{
+	"tv":         models.TypeTV,
+	"movie":      models.TypeMovie,
+	"ova":        models.TypeOVA,
+	"ona":        models.TypeONA,
+	"special":    models.TypeSpecial,
+	"tv special": models.TypeSpecial,
+}
+
+// ErrNotMALExport is returned for XML that is not a MyAnimeList export.
+var ErrNotMALExport = errors.New("xml is not a MyAnimeList export: expected a <myanimelist> root")
+
+type malList struct {
+	XMLName xml.Name   `xml:"myanimelist"`
+	Info    malInfo    `xml:"myinfo"`
+	Anime   []malAnime `xml:"anime"`
+}
+
+type malInfo struct {
+	ExportType     int `xml:"user_export_type"`
+	Total          int `xml:"user_total_anime"`
+	TotalWatching  int `xml:"user_total_watching"`
+	TotalCompleted int `xml:"user_total_completed"`
+	TotalOnHold    int `xml:"user_total_onhold"`
+	TotalDropped   int `xml:"user_total_dropped"`
+	TotalPlanning  int `xml:"user_total_plantowatch"`
+}
+
+type malAnime struct {
+	ID              int    `xml:"series_animedb_id"`
+	Title           cdata  `xml:"series_title"`
+	Type            string `xml:"series_type"`
+	Episodes        string `xml:"series_episodes"`
+	MyID            int    `xml:"my_id"`
+	WatchedEpisodes string `xml:"my_watched_episodes"`
+	StartDate       string `xml:"my_start_date"`
+	FinishDate      string `xml:"my_finish_date"`
+	Score           int    `xml:"my_score"`
+	Status          string `xml:"my_status"`
+	TimesWatched    int    `xml:"my_times_watched"`
+	Tags            cdata  `xml:"my_tags"`
+	UpdateOnImport  int    `xml:"update_on_import"`
+}
+
+// cdata writes its text as a CDATA section, as MyAnimeList does for
+// titles, and reads plain text or CDATA alike.
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+// ReadMAL reads import rows from a MyAnimeList XML export. Only the
+// fields the watch list stores are read; rows are matched by title.
+func ReadMAL(r io.Reader) ([]Row, error) {
+	decoder := xml.NewDecoder(r)
+	rootSeen := false
+	var rows []Row
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			if !rootSeen {
+				return nil, ErrNotMALExport
+			}
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case !rootSeen:
+			if start.Name.Local != "myanimelist" {
+				return nil, ErrNotMALExport
+			}
+			rootSeen = true
+		case start.Name.Local == "anime":
+			line, _ := decoder.InputPos()
+			var anime malAnime
+			if err := decoder.DecodeElement(&anime, &start); err != nil {
+				return nil, err
+			}
+			rows = append(rows, anime.row(line))
+		default:
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (a malAnime) row(line int) Row {
+	row := Row{Line: line, Fields: map[string]bool{}}
+	setField(&row, FieldTitle, strings.TrimSpace(a.Title.Text))
+	setField(&row, FieldTotalEpisodes, strings.TrimSpace(a.Episodes))
+	setField(&row, FieldWatchedEpisodes, strings.TrimSpace(a.WatchedEpisodes))
+
+	if raw := strings.TrimSpace(a.Type); raw != "" {
+		typ, ok := malTypes[strings.ToLower(raw)]
+		if !ok {
+			typ = raw
+		}
+		setField(&row, FieldType, typ)
+	}
+	if raw := strings.TrimSpace(a.Status); raw != "" {
+		status, ok := malStatuses[strings.ToLower(raw)]
+		if !ok {
+			row.Errors = append(row.Errors, models.FieldError{
+				Field:   "my_status",
+				Message: fmt.Sprintf("unknown MyAnimeList status %q", raw),
+			})
+		}
+		setField(&row, FieldStatus, status)
+	}
+	return row
+}
+
+// WriteMAL writes records as a MyAnimeList XML export, which MyAnimeList
+// and most other trackers can import. Records carry no MyAnimeList IDs,
+// so series_animedb_id is 0 and importers have to match by title.
+func WriteMAL(w io.Writer, records []models.Record) error {
+	list := malList{Info: malInfo{ExportType: 1, Total: len(records)}}
+	for _, record := range records {
+		switch record.Status {
+		case models.StatusWatching:
+			list.Info.TotalWatching++
+		case models.StatusCompleted:
+			list.Info.TotalCompleted++
+		case models.StatusOnHold:
+			list.Info.TotalOnHold++
+		case models.StatusDropped:
+			list.Info.TotalDropped++
+		case models.StatusPlanning:
+			list.Info.TotalPlanning++
+		}
+		list.Anime = append(list.Anime, malAnime{
+			Title:           cdata{record.Title},
+			Type:            malType(record.Type),
+			Episodes:        strconv.Itoa(record.TotalEpisodes),
+			WatchedEpisodes: strconv.Itoa(record.WatchedEpisodes),
+			StartDate:       "0000-00-00",
+			FinishDate:      "0000-00-00",
+			Status:          malStatus(record.Status),
+			UpdateOnImport:  1,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+	if err := encoder.Encode(list); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func malStatus(status string) string {
+	switch status {
+	case models.StatusWatching:
+		return "Watching"
+	case models.StatusCompleted:
+		return "Completed"
+	case models.StatusOnHold:
+		return "On-Hold"
+	case models.StatusDropped:
+		return "Dropped"
+	case models.StatusPlanning:
+		return "Plan to Watch"
+	}
+	return status
+}
+
+func malType(typ string) string {
+	switch typ {
+	case models.TypeTV:
+		return "TV"
+	case models.TypeMovie:
+		return "Movie"
+	case models.TypeOVA:
+		return "OVA"
+	case models.TypeONA:
+		return "ONA"
+	case models.TypeSpecial:
+		return "Special"
+	}
+	return typ
+}
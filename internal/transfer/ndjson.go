@@ -0,0 +1,151 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"io"
+	"reflect"
+)
+
+// MaxNDJSONLine bounds a single NDJSON line. Longer lines are skipped and
+// reported rather than buffered.
+const MaxNDJSONLine = 64 << 10
+
+// ndjsonRecord tells fields a line leaves out, or sets to null, apart from
+// fields set to their zero value.
+type ndjsonRecord struct {
+	ID              *int    `json:"id"`
+	Title           *string `json:"title"`
+	TotalEpisodes   *int    `json:"total_episodes"`
+	WatchedEpisodes *int    `json:"watched_episodes"`
+	Type            *string `json:"type"`
+	Status          *string `json:"status"`
+	Version         *int    `json:"version"`
+}
+
+// NDJSONReader reads import rows from newline-delimited JSON, one record
+// object per line, holding no more than one line in memory. Blank lines
+// are ignored. Lines that are not a record object become rows with
+// Malformed set, so the rest of the file is still imported.
+type NDJSONReader struct {
+	r    *bufio.Reader
+	line int
+	buf  []byte
+}
+
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next row, or io.EOF after the last one.
+func (n *NDJSONReader) Next() (Row, error) {
+	for {
+		data, tooLong, err := n.readLine()
+		if err != nil {
+			return Row{}, err
+		}
+		n.line++
+		row := Row{Line: n.line, Fields: map[string]bool{}}
+		if tooLong {
+			row.Malformed = fmt.Sprintf("line is longer than %d bytes", MaxNDJSONLine)
+			return row, nil
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var fields ndjsonRecord
+		if err := json.Unmarshal(data, &fields); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field != "" {
+				message := "must be a string"
+				if typeErr.Type.Kind() == reflect.Int {
+					message = "must be an integer"
+				}
+				row.Errors = append(row.Errors, models.FieldError{Field: typeErr.Field, Message: message})
+			} else {
+				row.Malformed = "invalid JSON: " + err.Error()
+			}
+			return row, nil
+		}
+		fields.apply(&row)
+		return row, nil
+	}
+}
+
+// readLine reads up to the next newline. A line over MaxNDJSONLine is read
+// to its end but not kept.
+func (n *NDJSONReader) readLine() ([]byte, bool, error) {
+	n.buf = n.buf[:0]
+	tooLong := false
+	for {
+		chunk, err := n.r.ReadSlice('\n')
+		if len(n.buf)+len(chunk) > MaxNDJSONLine {
+			tooLong = true
+		} else {
+			n.buf = append(n.buf, chunk...)
+		}
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && (len(n.buf) > 0 || tooLong):
+			// The last line has no trailing newline.
+			return n.buf, tooLong, nil
+		}
+		return n.buf, tooLong, err
+	}
+}
+
+func (f ndjsonRecord) apply(row *Row) {
+	setInt := func(field string, value *int, target *int) {
+		if value != nil {
+			*target = *value
+			row.Fields[field] = true
+		}
+	}
+	setString := func(field string, value *string, target *string) {
+		if value != nil {
+			*target = *value
+			row.Fields[field] = true
+		}
+	}
+	setInt(FieldID, f.ID, &row.Record.ID)
+	setString(FieldTitle, f.Title, &row.Record.Title)
+	setInt(FieldTotalEpisodes, f.TotalEpisodes, &row.Record.TotalEpisodes)
+	setInt(FieldWatchedEpisodes, f.WatchedEpisodes, &row.Record.WatchedEpisodes)
+	setString(FieldType, f.Type, &row.Record.Type)
+	setString(FieldStatus, f.Status, &row.Record.Status)
+	setInt(FieldVersion, f.Version, &row.Record.Version)
+}
+
+// NDJSONWriter writes records as newline-delimited JSON, one record object
+// per line, in the same form the API returns them.
+type NDJSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	buffered := bufio.NewWriter(w)
+	return &NDJSONWriter{w: buffered, enc: json.NewEncoder(buffered)}
+}
+
+// Write writes records and flushes them to the underlying writer.
+func (n *NDJSONWriter) Write(records []models.Record) error {
+	for _, record := range records {
+		if err := n.enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return n.w.Flush()
+}
+
+// Close flushes anything not yet written.
+func (n *NDJSONWriter) Close() error {
+	return n.w.Flush()
+}
@@ -0,0 +1,353 @@
+// Package transfer moves whole watch lists in and out of the repository
+// in file formats other tools understand.
+package transfer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"io"
+	"slices"
+)
+
+// Record fields an import row can supply, by their JSON names.
+const (
+	FieldID              = "id"
+	FieldTitle           = "title"
+	FieldTotalEpisodes   = "total_episodes"
+	FieldWatchedEpisodes = "watched_episodes"
+	FieldType            = "type"
+	FieldStatus          = "status"
+	FieldVersion         = "version"
+)
+
+// Row is one record read from an import file. Fields holds the fields the
+// file supplied; the others keep their stored values when the row updates
+// an existing record. Errors holds values that could not be parsed, and
+// Malformed explains a row that could not be read at all.
+type Row struct {
+	Line      int
+	Record    models.Record
+	Fields    map[string]bool
+	Errors    []models.FieldError
+	Malformed string
+}
+
+// RowReader reads import rows one at a time. Next returns io.EOF after the
+// last row; any other error stops the import.
+type RowReader interface {
+	Next() (Row, error)
+}
+
+// ReadError is returned by ImportFrom when the rows could not be read, as
+// opposed to written. Batches written before the failure stay written.
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string { return e.Err.Error() }
+
+func (e *ReadError) Unwrap() error { return e.Err }
+
+// NewRowReader reads rows from a slice.
+func NewRowReader(rows []Row) RowReader {
+	reader := sliceReader(rows)
+	return &reader
+}
+
+type sliceReader []Row
+
+func (s *sliceReader) Next() (Row, error) {
+	if len(*s) == 0 {
+		return Row{}, io.EOF
+	}
+	row := (*s)[0]
+	*s = (*s)[1:]
+	return row, nil
+}
+
+// MaxImportErrors is how many row errors a Summary lists.
+const MaxImportErrors = 100
+
+// Summary reports what an import did, or with DryRun what it would do.
+// Skipped counts rows that were not written, either because they match
+// the stored record or because they failed. Errors lists the failures of
+// the first MaxImportErrors failed rows, and MoreErrors counts the rest.
+type Summary struct {
+	DryRun     bool       `json:"dry_run"`
+	Created    int        `json:"created"`
+	Updated    int        `json:"updated"`
+	Skipped    int        `json:"skipped"`
+	Errors     []RowError `json:"errors"`
+	MoreErrors int        `json:"more_errors,omitempty"`
+}
+
+// addError lists e, keeping only the MaxImportErrors earliest rows. Rows
+// can fail out of order, since a batch is written after later rows were
+// checked.
+func (s *Summary) addError(e RowError) {
+	if len(s.Errors) < MaxImportErrors {
+		s.Errors = append(s.Errors, e)
+		return
+	}
+	s.MoreErrors++
+	latest := 0
+	for i, listed := range s.Errors {
+		if listed.Row > s.Errors[latest].Row {
+			latest = i
+		}
+	}
+	if e.Row < s.Errors[latest].Row {
+		s.Errors[latest] = e
+	}
+}
+
+// RowError explains why a row was skipped. Row is its line in the file.
+type RowError struct {
+	Row     int                 `json:"row"`
+	Message string              `json:"message"`
+	Errors  []models.FieldError `json:"errors,omitempty"`
+}
+
+// Import writes rows to repo. A row updates the record named by its id,
+// or else the record with the same normalized title, and creates a new
+// record when neither exists. Stored records are looked up one row at a
+// time, so an id from another instance's export falls back to the title.
+// Rows that match their record exactly are skipped. Each row is validated
+// and written on its own, so one bad row does not stop the others; with
+// dryRun nothing is written.
+func Import(ctx context.Context, repo repository.RecordRepositoryInterface, rows []Row, dryRun bool) (*Summary, error) {
+	return ImportFrom(ctx, repo, NewRowReader(rows), dryRun)
+}
+
+// ImportFrom is Import for rows read one at a time. Rows are written in
+// batches of up to repository.MaxBatchSize as they are read, and the next
+// row is not read until the batch before it is written, so a slow
+// database slows down reading instead of rows piling up in memory.
+// Memory stays flat however long the input is: a row repeating the title
+// of another row in the same batch is skipped, while a repeat in a later
+// batch finds the record the first row wrote (and, in a dry run, is
+// counted again), and only MaxImportErrors errors are kept.
+func ImportFrom(ctx context.Context, repo repository.RecordRepositoryInterface, rows RowReader, dryRun bool) (*Summary, error) {
+	summary := &Summary{DryRun: dryRun, Errors: []RowError{}}
+	skip := func(row Row, message string, fieldErrors ...models.FieldError) {
+		summary.Skipped++
+		summary.addError(RowError{Row: row.Line, Message: message, Errors: fieldErrors})
+	}
+
+	var ops []repository.BatchOperation
+	var planned []Row
+	// seen maps the title keys of the batch being planned to their rows.
+	seen := map[string]int{}
+	flush := func() error {
+		defer func() {
+			ops, planned = ops[:0], planned[:0]
+			clear(seen)
+		}()
+		if dryRun {
+			for _, op := range ops {
+				count(summary, op.Op)
+			}
+			return nil
+		}
+		if len(ops) == 0 {
+			return nil
+		}
+		results, err := repo.ApplyBatch(ctx, ops, false)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if result.Err == nil {
+				count(summary, ops[i].Op)
+				continue
+			}
+			message, fieldErrors, ok := rowFailure(result.Err)
+			if !ok {
+				return result.Err
+			}
+			skip(planned[i], message, fieldErrors...)
+		}
+		return nil
+	}
+
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &ReadError{Err: err}
+		}
+		if row.Malformed != "" {
+			skip(row, row.Malformed)
+			continue
+		}
+		if len(row.Errors) > 0 {
+			skip(row, "invalid record", row.Errors...)
+			continue
+		}
+
+		record := row.Record
+		record.Normalize()
+		stored, byID, err := match(ctx, repo, row)
+		if err != nil {
+			return nil, err
+		}
+		found := stored != nil
+		if !found && !row.Fields[FieldTitle] {
+			skip(row, fmt.Sprintf("anime record with ID %d not found", record.ID))
+			continue
+		}
+
+		if found {
+			record = overlay(*stored, row)
+			record.Normalize()
+		}
+		if err := record.Validate(); err != nil {
+			var verr *models.ValidationError
+			errors.As(err, &verr)
+			skip(row, "invalid record", verr.Errors...)
+			continue
+		}
+		key := duplicates.Key(record.Title)
+		if line, ok := seen[key]; ok && key != "" {
+			skip(row, fmt.Sprintf("same anime as row %d", line))
+			continue
+		}
+		seen[key] = row.Line
+
+		switch {
+		case !found:
+			ops = append(ops, repository.BatchOperation{Op: repository.BatchCreate, Record: record})
+		case sameFields(record, *stored):
+			summary.Skipped++
+			continue
+		default:
+			// A version only means something for the record it was read
+			// from, not one that happens to share the title.
+			version := 0
+			if byID && row.Fields[FieldVersion] {
+				version = row.Record.Version
+			}
+			ops = append(ops, repository.BatchOperation{Op: repository.BatchUpdate, ID: stored.ID, Version: version, Record: record})
+		}
+		planned = append(planned, row)
+
+		if len(ops) == repository.MaxBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(summary.Errors, func(a, b RowError) int { return cmp.Compare(a.Row, b.Row) })
+	return summary, nil
+}
+
+// match finds the stored record a row updates: the record named by its
+// id, or else the first record with the same normalized title. An id this
+// repository doesn't know, such as one exported from another instance,
+// falls back to the title. byID reports whether the id matched.
+func match(ctx context.Context, repo repository.RecordRepositoryInterface, row Row) (stored *models.Record, byID bool, err error) {
+	if row.Fields[FieldID] && row.Record.ID != 0 {
+		stored, err := repo.GetRecordByID(ctx, row.Record.ID)
+		if err == nil {
+			return stored, true, nil
+		}
+		if !errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, false, err
+		}
+	}
+	if !row.Fields[FieldTitle] {
+		return nil, false, nil
+	}
+	matches, err := repo.FindByTitle(ctx, row.Record.Title)
+	if err != nil || len(matches) == 0 {
+		return nil, false, err
+	}
+	return &matches[0], false, nil
+}
+
+// overlay copies the fields a row supplies onto the stored record.
+func overlay(stored models.Record, row Row) models.Record {
+	record := stored
+	if row.Fields[FieldTitle] {
+		record.Title = row.Record.Title
+	}
+	if row.Fields[FieldTotalEpisodes] {
+		record.TotalEpisodes = row.Record.TotalEpisodes
+	}
+	if row.Fields[FieldWatchedEpisodes] {
+		record.WatchedEpisodes = row.Record.WatchedEpisodes
+	}
+	if row.Fields[FieldType] {
+		record.Type = row.Record.Type
+	}
+	if row.Fields[FieldStatus] {
+		record.Status = row.Record.Status
+	}
+	return record
+}
+
+func sameFields(a, b models.Record) bool {
+	return a.Title == b.Title && a.TotalEpisodes == b.TotalEpisodes &&
+		a.WatchedEpisodes == b.WatchedEpisodes && a.Type == b.Type && a.Status == b.Status
+}
+
+func count(summary *Summary, op string) {
+	if op == repository.BatchCreate {
+		summary.Created++
+	} else {
+		summary.Updated++
+	}
+}
+
+// rowFailure describes the repository errors that concern a single row.
+// Other errors, such as an unavailable database, fail the whole import.
+func rowFailure(err error) (string, []models.FieldError, bool) {
+	var verr *models.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		return "invalid record", verr.Errors, true
+	case errors.Is(err, repository.ErrValidation):
+		return "rejected by the database", nil, true
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return "version does not match the stored record", nil, true
+	case errors.Is(err, repository.ErrRecordNotFound):
+		return "anime record no longer exists", nil, true
+	case errors.Is(err, repository.ErrDuplicateTitle):
+		return "title already exists", nil, true
+	case errors.Is(err, repository.ErrConflict):
+		return "conflicts with an existing record", nil, true
+	}
+	return "", nil, false
+}
+
+// EachPage calls fn with every record in ID order, a page at a time, so
+// that the whole list never has to be held in memory.
+func EachPage(ctx context.Context, repo repository.RecordRepositoryInterface, fn func([]models.Record) error) error {
+	opts := repository.ListOptions{SortBy: "id", Limit: repository.MaxListLimit}
+	for {
+		page, err := repo.ListRecords(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if len(page.Records) > 0 {
+			if err := fn(page.Records); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,64 @@
+package trash
+
+import (
+	"context"
+	"golang-watchlist/internal/repository"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultRetention is how long deleted records stay restorable.
+	DefaultRetention = 30 * 24 * time.Hour
+	// DefaultInterval is how often the purger looks for expired records.
+	DefaultInterval = time.Hour
+	// Actor is the actor purges are recorded under in the history.
+	Actor = "trash-purger"
+)
+
+// Store is the part of repository.RecordRepositoryInterface the purger
+// needs.
+type Store interface {
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+}
+
+// Purger permanently deletes records that have been in the trash longer
+// than Retention. A Retention of 0 keeps trashed records forever.
+type Purger struct {
+	Store     Store
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// PurgeOnce deletes every record trashed before now minus Retention.
+func (p *Purger) PurgeOnce(ctx context.Context, now time.Time) (int, error) {
+	if p.Retention == 0 {
+		return 0, nil
+	}
+	return p.Store.PurgeTrash(repository.WithActor(ctx, Actor), now.Add(-p.Retention))
+}
+
+// Run purges immediately and then every Interval until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	if p.Retention == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.PurgeOnce(ctx, time.Now())
+		if err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d records from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
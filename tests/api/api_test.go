@@ -3,6 +3,8 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
+	"golang-watchlist/internal/config"
 	"golang-watchlist/internal/db"
 	"golang-watchlist/internal/models"
 	"golang-watchlist/internal/routes"
@@ -21,7 +23,11 @@ func setupTestServer(t *testing.T) *mux.Router {
 	if err := godotenv.Load(envPath); err != nil {
 		t.Fatalf("Error loading .env file: %v", err)
 	}
-	db, err := db.NewDB()
+	cfg, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("Invalid configuration: %v", err)
+	}
+	db, err := db.Connect(cfg.Database)
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
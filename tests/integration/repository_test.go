@@ -3,10 +3,16 @@ package integration
 import (
 	"context"
 	"database/sql"
+	"flag"
+	"golang-watchlist/internal/config"
 	"golang-watchlist/internal/db"
 	"golang-watchlist/internal/models"
 	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/repository/repositorytest"
+	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -14,17 +20,77 @@ import (
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
+	return connect(t, loadConfig(t).Database)
+}
+
+func loadConfig(t *testing.T) *config.Config {
 	envPath := filepath.Join("..", "..", ".env")
 	if err := godotenv.Load(envPath); err != nil {
 		t.Fatalf("Error loading .env file: %v", err)
 	}
-	database, err := db.NewDB()
+	cfg, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("Invalid configuration: %v", err)
+	}
+	return cfg
+}
+
+func connect(t *testing.T, cfg db.Config) *sql.DB {
+	database, err := db.Connect(cfg)
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
 	return database
 }
 
+// TestRepositoryConformance runs the shared repository suite with the
+// configured driver. Every case starts from empty tables, so it runs
+// against a scratch database instead of the configured one: a temporary
+// file for SQLite, and the TEST_* database for MySQL and PostgreSQL.
+func TestRepositoryConformance(t *testing.T) {
+	cfg := loadConfig(t).Database
+
+	if cfg.Driver == db.SQLite {
+		repositorytest.Run(t, func(t *testing.T) repository.RecordRepositoryInterface {
+			cfg.SQLitePath = filepath.Join(t.TempDir(), "watchlist.db")
+			database := connect(t, cfg)
+			t.Cleanup(func() { database.Close() })
+			return repository.NewRecordRepository(database)
+		})
+		return
+	}
+
+	reset := []string{"TRUNCATE TABLE watch_list", "TRUNCATE TABLE watch_list_history"}
+	switch cfg.Driver {
+	case db.Postgres:
+		cfg.PostgresURL = os.Getenv("TEST_POSTGRES_URL")
+		reset = []string{"TRUNCATE watch_list, watch_list_history RESTART IDENTITY"}
+	case db.MySQL:
+		port, err := strconv.Atoi(os.Getenv("TEST_MYSQL_PORT"))
+		if err != nil {
+			t.Fatalf("Invalid TEST_MYSQL_PORT: %v", err)
+		}
+		cfg.MySQL = db.MySQLConfig{
+			User:     os.Getenv("TEST_MYSQL_USER"),
+			Password: os.Getenv("TEST_MYSQL_PASSWORD"),
+			Host:     os.Getenv("TEST_MYSQL_HOST"),
+			Port:     port,
+			Database: os.Getenv("TEST_MYSQL_DATABASE"),
+		}
+	}
+	database := connect(t, cfg)
+	defer database.Close()
+
+	repositorytest.Run(t, func(t *testing.T) repository.RecordRepositoryInterface {
+		for _, stmt := range reset {
+			if _, err := database.Exec(stmt); err != nil {
+				t.Fatalf("Failed to reset test tables: %v", err)
+			}
+		}
+		return repository.NewRecordRepository(database)
+	})
+}
+
 func TestCreateAndGetRecord(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -129,3 +195,56 @@ func TestDeleteRecord(t *testing.T) {
 		}
 	}
 }
+
+func TestMigrations(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			migrator, err := db.NewMigrator(database)
+			if err != nil {
+				t.Errorf("Failed to create migrator: %v", err)
+				return
+			}
+			if _, err := migrator.Up(context.Background()); err != nil {
+				t.Errorf("Failed to run concurrent migration: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	statuses, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("Expected migration %d_%s to be applied", s.Version, s.Name)
+		}
+	}
+
+	reverted, err := migrator.Down(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Failed to revert migration: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != statuses[len(statuses)-1].Version {
+		t.Errorf("Expected the latest migration to be reverted, got %+v", reverted)
+	}
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to reapply migration: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Errorf("Expected one migration to be reapplied, got %d", len(applied))
+	}
+}
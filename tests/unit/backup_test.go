@@ -0,0 +1,213 @@
+package unit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"golang-watchlist/internal/backup"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupArchive(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer database.Close()
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("Failed to migrate SQLite database: %v", err)
+	}
+	source := repository.NewRecordRepository(database)
+	for i := range repository.MaxListLimit + 3 {
+		record := models.Record{Title: "Anime " + strings.Repeat("I", i+1), TotalEpisodes: 12, Type: "tv", Status: "planning"}
+		if err := source.CreateRecord(ctx, &record); err != nil {
+			t.Fatalf("Failed to seed record: %v", err)
+		}
+	}
+
+	for _, id := range []int{2, 5} {
+		if err := source.DeleteRecord(ctx, id); err != nil {
+			t.Fatalf("Failed to delete record: %v", err)
+		}
+	}
+
+	var archive bytes.Buffer
+	count, trashed, err := backup.Write(ctx, &archive, source, backup.Header{SchemaVersion: 5, Driver: db.SQLite})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if count != repository.MaxListLimit+3 || trashed != 2 {
+		t.Errorf("Expected %d records written, 2 in the trash, got %d and %d", repository.MaxListLimit+3, count, trashed)
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		read, err := backup.Read(bytes.NewReader(archive.Bytes()))
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if read.Format != backup.Format || read.SchemaVersion != 5 || read.Driver != db.SQLite || read.CreatedAt.IsZero() {
+			t.Errorf("Unexpected header %+v", read.Header)
+		}
+		stored, _ := source.GetRecords(ctx)
+		if len(read.Records) != len(stored) || read.Records[0] != stored[0] {
+			t.Errorf("Expected the stored records back, got %d starting with %+v", len(read.Records), read.Records[0])
+		}
+		trash, _ := source.ListTrash(ctx)
+		if len(read.Trash) != 2 || read.Trash[0].ID != 2 || read.Trash[1].ID != 5 ||
+			!read.Trash[0].DeletedAt.Equal(trash[1].DeletedAt) || read.Trash[1].Record != trash[0].Record {
+			t.Errorf("Expected the trashed records back, got %+v", read.Trash)
+		}
+	})
+
+	t.Run("Restore into an empty list", func(t *testing.T) {
+		read, err := backup.Read(bytes.NewReader(archive.Bytes()))
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		target := repository.NewMemoryRecordRepository()
+		result, err := backup.Restore(ctx, target, read, backup.Skip)
+		if err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if result.Created != repository.MaxListLimit+1 || result.Trashed != 2 || len(result.Failed) != 0 {
+			t.Errorf("Expected every record restored, got %+v", result)
+		}
+		trash, _ := target.ListTrash(ctx)
+		if len(trash) != 2 || trash[0].Title != read.Trash[1].Title || trash[1].Title != read.Trash[0].Title {
+			t.Errorf("Expected the archived trash back in the trash, got %+v", trash)
+		}
+	})
+
+	t.Run("Corruption", func(t *testing.T) {
+		contents := gunzip(t, archive.Bytes())
+		tampered := strings.Replace(contents, `"watched_episodes":0`, `"watched_episodes":1`, 1)
+		lines := strings.SplitAfter(contents, "\n")
+		truncated := strings.Join(append(lines[:3:3], lines[len(lines)-2]), "")
+		newer := strings.Replace(contents, `"format_version":2`, `"format_version":3`, 1)
+
+		tests := []struct {
+			name  string
+			input []byte
+			err   error
+		}{
+			{"Not gzip", []byte("id,title\n"), backup.ErrNotBackup},
+			{"Not a backup", gzipped(t, "{\"title\":\"Blue Lock\"}\n"), backup.ErrNotBackup},
+			{"Tampered record", gzipped(t, tampered), backup.ErrCorrupt},
+			{"Missing records", gzipped(t, truncated), backup.ErrCorrupt},
+			{"Missing trailer", gzipped(t, strings.Join(lines[:len(lines)-2], "")), backup.ErrCorrupt},
+			{"Cut short", archive.Bytes()[:archive.Len()/2], backup.ErrCorrupt},
+			{"Newer format", gzipped(t, newer), nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := backup.Read(bytes.NewReader(tt.input))
+				if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+					t.Errorf("Expected %v, got %v", tt.err, err)
+				}
+			})
+		}
+	})
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	archive := &backup.Archive{Records: []models.Record{
+		{ID: 1, Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 12, Type: "tv", Status: "watching", Version: 4},
+		{ID: 2, Title: "Monster", TotalEpisodes: 74, Type: "tv", Status: "planning", Version: 1},
+		{ID: 3, Title: "Frieren", TotalEpisodes: 28, WatchedEpisodes: 28, Type: "tv", Status: "completed", Version: 2},
+		{ID: 4, Title: "Frieren", TotalEpisodes: 28, Type: "tv", Status: "planning", Version: 1},
+		{ID: 5, Title: "Broken", Type: "tv", Status: "binging", Version: 1},
+	}, Trash: []models.TrashedRecord{
+		{Record: models.Record{ID: 6, Title: "Akira", TotalEpisodes: 1, Type: "movie", Status: "planning", Version: 2}, DeletedAt: time.Now()},
+		{Record: models.Record{ID: 7, Title: "Trigun", TotalEpisodes: 26, Type: "tv", Status: "dropped", Version: 2}, DeletedAt: time.Now()},
+	}}
+	seed := func(t *testing.T) *repository.MemoryRecordRepository {
+		repo := repository.NewMemoryRecordRepository()
+		for _, record := range []models.Record{
+			{Title: "blue lock", TotalEpisodes: 24, WatchedEpisodes: 20, Type: "tv", Status: "on-hold"},
+			{Title: "Monster", TotalEpisodes: 74, Type: "tv", Status: "planning"},
+			{Title: "Trigun", TotalEpisodes: 26, Type: "tv", Status: "planning"},
+		} {
+			if err := repo.CreateRecord(ctx, &record); err != nil {
+				t.Fatalf("Failed to seed record: %v", err)
+			}
+		}
+		return repo
+	}
+
+	tests := []struct {
+		policy    backup.Policy
+		updated   int
+		unchanged int
+		blueLock  models.Record
+	}{
+		{backup.Replace, 1, 2, models.Record{Title: "Blue Lock", WatchedEpisodes: 12, Status: "watching"}},
+		{backup.Merge, 1, 2, models.Record{Title: "blue lock", WatchedEpisodes: 20, Status: "watching"}},
+		{backup.Skip, 0, 3, models.Record{Title: "blue lock", WatchedEpisodes: 20, Status: "on-hold"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			repo := seed(t)
+			result, err := backup.Restore(ctx, repo, archive, tt.policy)
+			if err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+			if result.Created != 2 || result.Updated != tt.updated || result.Trashed != 1 || result.Unchanged != tt.unchanged {
+				t.Errorf("Expected 2 created, %d updated, 1 trashed and %d unchanged, got %+v", tt.updated, tt.unchanged, result)
+			}
+			if len(result.Failed) != 1 || result.Failed[0].Record.ID != 5 {
+				t.Errorf("Expected the invalid record to fail, got %+v", result.Failed)
+			}
+
+			got := getRecord(t, repo, 1)
+			if got.Title != tt.blueLock.Title || got.WatchedEpisodes != tt.blueLock.WatchedEpisodes || got.Status != tt.blueLock.Status {
+				t.Errorf("Expected %+v, got %+v", tt.blueLock, got)
+			}
+			records, _ := repo.GetRecords(ctx)
+			if len(records) != 5 || records[2].Title != "Trigun" || records[2].Status != "planning" {
+				t.Errorf("Expected two Frieren records added and Trigun kept, got %+v", records)
+			}
+			// Trigun's trashed copy is left out, since the title is
+			// already stored.
+			if trash, _ := repo.ListTrash(ctx); len(trash) != 1 || trash[0].Title != "Akira" {
+				t.Errorf("Expected only Akira restored into the trash, got %+v", trash)
+			}
+		})
+	}
+
+	if _, err := backup.ParsePolicy("overwrite"); err == nil {
+		t.Error("Expected an unknown policy to be rejected")
+	}
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	contents, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	return string(contents)
+}
+
+func gzipped(t *testing.T, contents string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(contents)); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"golang-watchlist/internal/rules"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type batchResponse struct {
+	Atomic  bool `json:"atomic"`
+	Results []struct {
+		Status int             `json:"status"`
+		Record *models.Record  `json:"record"`
+		Error  *routes.Problem `json:"error"`
+	} `json:"results"`
+}
+
+func TestBatchRoutes(t *testing.T) {
+	repo := repository.NewMemoryRecordRepository(rules.WithEngine(rules.DefaultEngine()))
+	router := mux.NewRouter()
+	routes.RegisterRecordRoutes(router, repo)
+
+	post := func(body string) (*httptest.ResponseRecorder, batchResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/watchlist/batch", strings.NewReader(body)))
+		var resp batchResponse
+		if w.Header().Get("Content-Type") != "application/problem+json" {
+			if err := json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v: %s", err, w.Body.String())
+			}
+		}
+		return w, resp
+	}
+
+	t.Run("Atomic success", func(t *testing.T) {
+		w, resp := post(`{"operations": [
+			{"op": "create", "record": {"title": "Toradora!", "total_episodes": 25, "watched_episodes": 25, "type": "tv", "status": "watching"}},
+			{"op": "create", "record": {"title": "Nichijou", "total_episodes": 26, "type": "tv", "status": "planning"}},
+			{"op": "update", "id": 2, "version": 1, "record": {"title": "Nichijou", "total_episodes": 26, "watched_episodes": 3, "type": "tv", "status": "watching"}},
+			{"op": "delete", "id": 2}
+		]}`)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !resp.Atomic || len(resp.Results) != 4 {
+			t.Fatalf("Expected 4 atomic results, got %+v", resp)
+		}
+		for i, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusOK, http.StatusNoContent} {
+			if resp.Results[i].Status != want {
+				t.Errorf("Operation %d: expected status %d, got %d", i, want, resp.Results[i].Status)
+			}
+		}
+		if record := resp.Results[0].Record; record == nil || record.Status != "completed" {
+			t.Errorf("Expected status rules to complete the finished record, got %+v", record)
+		}
+	})
+
+	t.Run("Atomic failure writes nothing", func(t *testing.T) {
+		w, resp := post(`{"operations": [
+			{"op": "create", "record": {"title": "K-On!", "type": "tv", "status": "planning"}},
+			{"op": "delete", "id": 99}
+		]}`)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+		}
+		if r := resp.Results[0]; r.Status != http.StatusFailedDependency || r.Error == nil || r.Error.Code != routes.CodeBatchAborted {
+			t.Errorf("Expected operation 0 to be aborted, got %+v", r)
+		}
+		if r := resp.Results[1]; r.Status != http.StatusNotFound || r.Error == nil || r.Error.Code != routes.CodeNotFound {
+			t.Errorf("Expected operation 1 to be not found, got %+v", r)
+		}
+		if records, _ := repo.GetRecords(context.Background()); len(records) != 1 {
+			t.Errorf("Expected only the record from the first batch, got %+v", records)
+		}
+	})
+
+	t.Run("Per-item results", func(t *testing.T) {
+		w, resp := post(`{"atomic": false, "operations": [
+			{"op": "create", "record": {"title": "Lucky Star", "type": "tv", "status": "planning"}},
+			{"op": "update", "id": 1, "version": 7, "record": {"title": "Toradora!", "type": "tv", "status": "completed"}},
+			{"op": "create", "record": {"title": "", "type": "tv", "status": "planning"}}
+		]}`)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if resp.Atomic {
+			t.Error("Expected a non-atomic response")
+		}
+		for i, want := range []int{http.StatusCreated, http.StatusPreconditionFailed, http.StatusBadRequest} {
+			if resp.Results[i].Status != want {
+				t.Errorf("Operation %d: expected status %d, got %d", i, want, resp.Results[i].Status)
+			}
+		}
+		if p := resp.Results[2].Error; p == nil || len(p.Errors) == 0 || p.Errors[0].Field != "title" {
+			t.Errorf("Expected field errors for operation 2, got %+v", p)
+		}
+	})
+
+	t.Run("Malformed batches are rejected", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"Invalid JSON":      `{"operations": [`,
+			"No operations":     `{"operations": []}`,
+			"Unknown operation": `{"operations": [{"op": "upsert", "id": 1}]}`,
+			"Missing ID":        `{"operations": [{"op": "delete"}]}`,
+			"Missing record":    `{"operations": [{"op": "update", "id": 1}]}`,
+		} {
+			w, _ := post(body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", name, http.StatusBadRequest, w.Code)
+			}
+		}
+		if records, _ := repo.GetRecords(context.Background()); len(records) != 2 {
+			t.Errorf("Expected malformed batches to write nothing, got %+v", records)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package unit
+
+import (
+	"flag"
+	"golang-watchlist/internal/config"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/duplicates"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configEnv are the environment variables config.Load reads.
+var configEnv = []string{
+	"CONFIG_FILE", "APP_PORT", "DB_DRIVER", "SQLITE_PATH", "POSTGRES_URL",
+	"MYSQL_HOST", "MYSQL_PORT", "MYSQL_USER", "MYSQL_PASSWORD", "MYSQL_DATABASE",
+	"TRASH_RETENTION", "TRASH_PURGE_INTERVAL", "IDEMPOTENCY_TTL", "IDEMPOTENCY_LEASE", "DUPLICATE_TITLES", "STATUS_RULES",
+}
+
+// isolateConfig unsets configEnv and moves to an empty directory, so only
+// what the test sets up is loaded.
+func isolateConfig(t *testing.T) string {
+	t.Helper()
+	for _, name := range configEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			t.Cleanup(func() { os.Setenv(name, value) })
+		}
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	return dir
+}
+
+func loadConfig(t *testing.T, args ...string) (*config.Config, error) {
+	t.Helper()
+	return config.Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestConfigLoad(t *testing.T) {
+	t.Run("Defaults without a .env file", func(t *testing.T) {
+		isolateConfig(t)
+		t.Setenv("MYSQL_DATABASE", "anime-watch-list")
+		cfg, err := loadConfig(t)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.Port != 8080 || cfg.Database.Driver != db.MySQL || cfg.Trash.Retention != 30*24*time.Hour ||
+			cfg.IdempotencyTTL != 24*time.Hour || cfg.DuplicateTitles != duplicates.Warn || cfg.StatusRules != "complete,start" {
+			t.Errorf("Unexpected defaults %+v", cfg)
+		}
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		dir := isolateConfig(t)
+		writeFile(t, ".env", "APP_PORT=7000\nSQLITE_PATH=dotenv.db\nTRASH_RETENTION=1h\nTRASH_PURGE_INTERVAL=5m\n")
+		writeFile(t, filepath.Join(dir, "watchlist.yaml"), `
+port: 9000
+database:
+  driver: sqlite
+  sqlite_path: file.db
+trash:
+  purge_interval: 10m
+status_rules: [complete, reopen]
+`)
+		t.Setenv("CONFIG_FILE", "watchlist.yaml")
+		t.Setenv("APP_PORT", "9100")
+		t.Setenv("TRASH_PURGE_INTERVAL", "20m")
+
+		cfg, err := loadConfig(t, "-port", "9200")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.Port != 9200 {
+			t.Errorf("Expected the flag to win, got port %d", cfg.Port)
+		}
+		if cfg.Database.Driver != db.SQLite || cfg.Database.SQLitePath != "dotenv.db" {
+			t.Errorf("Expected .env to override the file, got %+v", cfg.Database)
+		}
+		if cfg.Trash.Retention != time.Hour || cfg.Trash.PurgeInterval != 20*time.Minute {
+			t.Errorf("Expected retention from .env and interval from the environment, got %+v", cfg.Trash)
+		}
+		if cfg.StatusRules != "complete,reopen" {
+			t.Errorf("Expected the rule list joined, got %q", cfg.StatusRules)
+		}
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		dir := isolateConfig(t)
+		path := filepath.Join(dir, "watchlist.toml")
+		writeFile(t, path, `
+duplicate_titles = "reject"
+idempotency_ttl = "48h"
+
+[database]
+driver = "postgres"
+postgres_url = "postgres://app:secret@db:5432/watchlist"
+`)
+		cfg, err := loadConfig(t, "-config", path)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.DuplicateTitles != duplicates.Reject || cfg.IdempotencyTTL != 48*time.Hour || cfg.Database.Driver != db.Postgres {
+			t.Errorf("Unexpected config %+v", cfg)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		dir := isolateConfig(t)
+		path := filepath.Join(dir, "watchlist.yaml")
+		writeFile(t, path, "database:\n  driver: sqlite\n  sqlite_pth: typo.db\n")
+		t.Setenv("IDEMPOTENCY_TTL", "forever")
+		t.Setenv("TRASH_RETENTION", "a month")
+		t.Setenv("TRASH_PURGE_INTERVAL", "0")
+		t.Setenv("DUPLICATE_TITLES", "sometimes")
+		t.Setenv("STATUS_RULES", "complete,binge")
+
+		cfg, err := loadConfig(t, "-config", path, "-port", "99999")
+		if err == nil || cfg == nil {
+			t.Fatalf("Expected errors and the config, got %v, %v", cfg, err)
+		}
+		for _, want := range []string{"IDEMPOTENCY_TTL", "TRASH_RETENTION", "trash.purge_interval", "DUPLICATE_TITLES",
+			"status_rules", `"database.sqlite_pth"`, "port (APP_PORT)"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected an error about %s, got:\n%v", want, err)
+			}
+		}
+	})
+
+	t.Run("Trash settings", func(t *testing.T) {
+		isolateConfig(t)
+		t.Setenv("DB_DRIVER", "memory")
+		t.Setenv("TRASH_RETENTION", "0")
+		t.Setenv("TRASH_PURGE_INTERVAL", "10m")
+		cfg, err := loadConfig(t)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.Trash.Retention != 0 || cfg.Trash.PurgeInterval != 10*time.Minute {
+			t.Errorf("Expected retention 0 and interval 10m, got %+v", cfg.Trash)
+		}
+	})
+}
+
+func TestConfigPrint(t *testing.T) {
+	dir := isolateConfig(t)
+	t.Setenv("DB_DRIVER", "postgres")
+	t.Setenv("POSTGRES_URL", "postgres://app:hunter2@db:5432/watchlist")
+	t.Setenv("MYSQL_PASSWORD", "hunter2")
+	cfg, err := loadConfig(t, "-duplicate-titles", "allow")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	printed := out.String()
+	if strings.Contains(printed, "hunter2") {
+		t.Errorf("Expected secrets redacted, got:\n%s", printed)
+	}
+	for _, want := range []string{
+		"port: 8080 # default",
+		"driver: postgres # DB_DRIVER",
+		"postgres_url: postgres://app:xxxxx@db:5432/watchlist # POSTGRES_URL",
+		"password: REDACTED # MYSQL_PASSWORD",
+		"duplicate_titles: allow # -duplicate-titles",
+	} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("Expected %q in:\n%s", want, printed)
+		}
+	}
+
+	t.Run("Connection strings", func(t *testing.T) {
+		tests := map[string]string{
+			"postgres://app:hunter2@db:5432/watchlist":                  "postgres://app:xxxxx@db:5432/watchlist",
+			"postgres://db/watchlist?password=hunter2&user=app":         "postgres://db/watchlist?password=xxxxx&user=app",
+			"postgres://db/watchlist?sslmode=disable&PASSWORD=hunter2":  "postgres://db/watchlist?sslmode=disable&PASSWORD=xxxxx",
+			"host=db user=app password=hunter2 dbname=watchlist":        "host=db user=app password=xxxxx dbname=watchlist",
+			"host=db password = 'hunter2 \\' hunter2' dbname=watchlist": "host=db password = xxxxx dbname=watchlist",
+			"postgres://app:hunter2@db:5432/%zz":                        "REDACTED",
+		}
+		for dsn, want := range tests {
+			// A URL that can't be parsed fails validation, but the
+			// configuration is still returned to be printed.
+			t.Setenv("POSTGRES_URL", dsn)
+			cfg, err := loadConfig(t)
+			if cfg == nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			var out strings.Builder
+			if err := cfg.Print(&out); err != nil {
+				t.Fatalf("Print failed: %v", err)
+			}
+			if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), "postgres_url: "+want+" # POSTGRES_URL") {
+				t.Errorf("Expected %q redacted to %q, got:\n%s", dsn, want, out.String())
+			}
+		}
+	})
+
+	// The output is itself a valid config file.
+	path := filepath.Join(dir, "printed.yaml")
+	writeFile(t, path, printed)
+	reloaded, err := loadConfig(t, "-config", path)
+	if err != nil {
+		t.Fatalf("Failed to load the printed config: %v", err)
+	}
+	if reloaded.DuplicateTitles != duplicates.Allow || reloaded.Trash != cfg.Trash || reloaded.Port != cfg.Port {
+		t.Errorf("Expected the printed config back, got %+v", reloaded)
+	}
+}
@@ -0,0 +1,59 @@
+package unit
+
+import (
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/repository/repositorytest"
+	"os"
+	"testing"
+)
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.RecordRepositoryInterface {
+		return repository.NewMemoryRecordRepository()
+	})
+}
+
+func TestSQLiteRepositoryConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.RecordRepositoryInterface {
+		database, err := db.OpenSQLite(":memory:")
+		if err != nil {
+			t.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		t.Cleanup(func() { database.Close() })
+		if err := db.Migrate(database); err != nil {
+			t.Fatalf("Failed to migrate SQLite database: %v", err)
+		}
+		return repository.NewRecordRepository(database)
+	})
+}
+
+// TestRepositoryConformance_Database runs the suite against the test
+// database selected by DB_DRIVER.
+func TestRepositoryConformance_Database(t *testing.T) {
+	skipIfNoDatabase(t)
+
+	repositorytest.Run(t, func(t *testing.T) repository.RecordRepositoryInterface {
+		resetTestTable(t)
+		t.Cleanup(func() { resetTestTable(t) })
+		return repository.NewRecordRepository(testDB)
+	})
+}
+
+// resetTestTable empties watch_list and its history and restarts their
+// ids, so the other database tests still find a fresh table.
+func resetTestTable(t *testing.T) {
+	statements := []string{"TRUNCATE TABLE watch_list", "TRUNCATE TABLE watch_list_history"}
+	switch os.Getenv("DB_DRIVER") {
+	case db.SQLite:
+		statements = []string{"DELETE FROM watch_list", "DELETE FROM watch_list_history",
+			"DELETE FROM sqlite_sequence WHERE name IN ('watch_list', 'watch_list_history')"}
+	case db.Postgres:
+		statements = []string{"TRUNCATE watch_list, watch_list_history RESTART IDENTITY"}
+	}
+	for _, stmt := range statements {
+		if _, err := testDB.Exec(stmt); err != nil {
+			t.Fatalf("Failed to reset test tables: %v", err)
+		}
+	}
+}
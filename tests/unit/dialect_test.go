@@ -0,0 +1,38 @@
+package unit
+
+import (
+	"golang-watchlist/internal/placeholder"
+	"testing"
+)
+
+func TestDollarPlaceholders(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "SELECT id FROM watch_list WHERE id = ?",
+			expected: "SELECT id FROM watch_list WHERE id = $1",
+		},
+		{
+			query:    "UPDATE watch_list SET title = ?, status = ? WHERE id = ? AND version = ?",
+			expected: "UPDATE watch_list SET title = $1, status = $2 WHERE id = $3 AND version = $4",
+		},
+		{
+			query:    "SELECT id FROM watch_list WHERE title ILIKE ? ESCAPE '!' AND status <> '?' LIMIT ?",
+			expected: "SELECT id FROM watch_list WHERE title ILIKE $1 ESCAPE '!' AND status <> '?' LIMIT $2",
+		},
+		{
+			query:    "SELECT 1",
+			expected: "SELECT 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := placeholder.Dollar(tt.query); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
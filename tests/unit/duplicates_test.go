@@ -0,0 +1,365 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDuplicateTitles(t *testing.T) {
+	t.Run("Key", func(t *testing.T) {
+		tests := map[string]string{
+			"Blue Lock":                        "blue lock",
+			" blue lock ":                      "blue lock",
+			"BLUE  LOCK!":                      "blue lock",
+			"Re:Zero − Starting Life":          "re zero starting life",
+			"Blue-Lock":                        "blue lock",
+			"Fullmetal Alchemist: Brotherhood": "fullmetal alchemist brotherhood",
+			"進撃の巨人":                            "進撃の巨人",
+		}
+		for title, want := range tests {
+			if got := duplicates.Key(title); got != want {
+				t.Errorf("Key(%q) = %q, want %q", title, got, want)
+			}
+		}
+	})
+
+	t.Run("Similarity", func(t *testing.T) {
+		if got := duplicates.Similarity("Blue Lock", "blue lock "); got != 1 {
+			t.Errorf("Expected matching keys to score 1, got %v", got)
+		}
+		if got := duplicates.Similarity("Steins;Gate", "Steins Gate"); got < 0.9 {
+			t.Errorf("Expected near-identical titles to score high, got %v", got)
+		}
+		if got := duplicates.Similarity("Monster", "Baccano!"); got > 0.5 {
+			t.Errorf("Expected different titles to score low, got %v", got)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		records := []models.Record{
+			{ID: 3, Title: "blue lock "},
+			{ID: 1, Title: "Blue Lock"},
+			{ID: 2, Title: "Monster"},
+			{ID: 4, Title: "Fullmetal Alchemist"},
+			{ID: 5, Title: "Full Metal Alchemist"},
+		}
+		pairs := duplicates.Find(records, duplicates.DefaultThreshold)
+		if len(pairs) != 2 {
+			t.Fatalf("Expected 2 pairs, got %+v", pairs)
+		}
+		if p := pairs[0]; p.Records[0].ID != 1 || p.Records[1].ID != 3 || p.Similarity != 1 {
+			t.Errorf("Expected Blue Lock pair first, got %+v", p)
+		}
+		if p := pairs[1]; p.Records[0].ID != 4 || p.Records[1].ID != 5 || p.Similarity >= 1 {
+			t.Errorf("Expected the Fullmetal Alchemist pair second, got %+v", p)
+		}
+		if pairs := duplicates.Find(records, 1); len(pairs) != 1 {
+			t.Errorf("Expected only exact matches at threshold 1, got %+v", pairs)
+		}
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		if policy, err := duplicates.ParsePolicy(" Reject"); err != nil || policy != duplicates.Reject {
+			t.Errorf("Expected reject, got %q, %v", policy, err)
+		}
+		if _, err := duplicates.ParsePolicy("sometimes"); err == nil {
+			t.Error("Expected an unknown policy to be rejected")
+		}
+	})
+}
+
+func TestRecordMerge(t *testing.T) {
+	tests := []struct {
+		name        string
+		keep, other models.Record
+		want        models.Record
+	}{
+		{
+			name:  "Furthest progress and status",
+			keep:  models.Record{ID: 1, Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 3, Type: "tv", Status: "planning"},
+			other: models.Record{ID: 2, Title: "blue lock", TotalEpisodes: 0, WatchedEpisodes: 10, Type: "ona", Status: "watching"},
+			want:  models.Record{ID: 1, Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 10, Type: "tv", Status: "watching"},
+		},
+		{
+			name:  "Completed beats watching",
+			keep:  models.Record{ID: 1, Title: "Monster", TotalEpisodes: 74, WatchedEpisodes: 74, Type: "tv", Status: "completed"},
+			other: models.Record{ID: 2, Title: "Monster", TotalEpisodes: 74, WatchedEpisodes: 20, Type: "tv", Status: "watching"},
+			want:  models.Record{ID: 1, Title: "Monster", TotalEpisodes: 74, WatchedEpisodes: 74, Type: "tv", Status: "completed"},
+		},
+		{
+			name:  "Total raised to progress",
+			keep:  models.Record{ID: 1, Title: "One Piece", TotalEpisodes: 1000, WatchedEpisodes: 900, Type: "tv", Status: "watching"},
+			other: models.Record{ID: 2, Title: "One Piece", WatchedEpisodes: 1100, Type: "tv", Status: "watching"},
+			want:  models.Record{ID: 1, Title: "One Piece", TotalEpisodes: 1100, WatchedEpisodes: 1100, Type: "tv", Status: "watching"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.keep.Merge(tt.other); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDuplicateRoutes(t *testing.T) {
+	newRouter := func(policy duplicates.Policy) (*mux.Router, *repository.MemoryRecordRepository) {
+		repo := repository.NewMemoryRecordRepository(repository.WithDuplicateTitles(policy))
+		repo.CreateRecord(context.Background(), &models.Record{Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 5, Type: "tv", Status: "watching"})
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo, routes.WithDuplicatePolicy(policy))
+		return router, repo
+	}
+	duplicate := models.Record{Title: "blue lock ", TotalEpisodes: 24, WatchedEpisodes: 12, Type: "tv", Status: "watching"}
+
+	t.Run("Warn", func(t *testing.T) {
+		router, _ := newRouter(duplicates.Warn)
+		w := postWithKey(router, "", duplicate)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Duplicate-Of"); got != "1" {
+			t.Errorf("Expected X-Duplicate-Of 1, got %q", got)
+		}
+
+		w = postWithKey(router, "", models.Record{Title: "Monster", Type: "tv", Status: "planning"})
+		if got := w.Header().Get("X-Duplicate-Of"); got != "" {
+			t.Errorf("Expected no duplicate warning, got %q", got)
+		}
+
+		big := duplicate
+		big.Title = strings.Repeat("a", 2<<20)
+		assertProblem(t, postWithKey(router, "", big), http.StatusRequestEntityTooLarge, routes.CodeValidation)
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		router, repo := newRouter(duplicates.Reject)
+		w := postWithKey(router, "", duplicate)
+		assertProblem(t, w, http.StatusConflict, routes.CodeDuplicateTitle)
+
+		records, _ := repo.GetRecords(context.Background())
+		if len(records) != 1 {
+			t.Errorf("Expected the duplicate not to be created, got %+v", records)
+		}
+
+		postWithKey(router, "", models.Record{Title: "Monster", Type: "tv", Status: "planning"})
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/watchlist/2", bytes.NewBufferString(`{"title": "BLUE LOCK"}`)))
+		assertProblem(t, w, http.StatusConflict, routes.CodeDuplicateTitle)
+	})
+
+	t.Run("Allow", func(t *testing.T) {
+		router, _ := newRouter(duplicates.Allow)
+		w := postWithKey(router, "", duplicate)
+		if w.Code != http.StatusCreated || w.Header().Get("X-Duplicate-Of") != "" {
+			t.Errorf("Expected the duplicate to be created silently, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		router, _ := newRouter(duplicates.Allow)
+		postWithKey(router, "", duplicate)
+		postWithKey(router, "", models.Record{Title: "Monster", Type: "tv", Status: "planning"})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/duplicates", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var pairs []duplicates.Pair
+		if err := json.NewDecoder(w.Body).Decode(&pairs); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(pairs) != 1 || pairs[0].Records[0].ID != 1 || pairs[0].Records[1].ID != 2 {
+			t.Errorf("Expected records 1 and 2 paired, got %+v", pairs)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/duplicates?threshold=2", nil))
+		assertProblem(t, w, http.StatusBadRequest, routes.CodeValidation)
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		router, repo := newRouter(duplicates.Allow)
+		postWithKey(router, "", duplicate)
+
+		merge := func(body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/watchlist/merge", bytes.NewBufferString(body)))
+			return w
+		}
+
+		w := merge(`{"keep": 1, "remove": 2}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var merged models.Record
+		if err := json.NewDecoder(w.Body).Decode(&merged); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if merged.ID != 1 || merged.WatchedEpisodes != 12 || w.Header().Get("ETag") != `"2"` {
+			t.Errorf("Unexpected merged record %+v, ETag %s", merged, w.Header().Get("ETag"))
+		}
+		trash, _ := repo.ListTrash(context.Background())
+		if len(trash) != 1 || trash[0].ID != 2 {
+			t.Errorf("Expected record 2 in the trash, got %+v", trash)
+		}
+
+		assertProblem(t, merge(`{"keep": 1, "remove": 2}`), http.StatusNotFound, routes.CodeNotFound)
+		assertProblem(t, merge(`{"keep": 1, "remove": 1}`), http.StatusBadRequest, routes.CodeValidation)
+		assertProblem(t, merge(`{"keep": 1}`), http.StatusBadRequest, routes.CodeValidation)
+	})
+}
+
+func TestDuplicateTitlesRejected(t *testing.T) {
+	repos := map[string]func(t *testing.T) repository.RecordRepositoryInterface{
+		"Memory": func(t *testing.T) repository.RecordRepositoryInterface {
+			return repository.NewMemoryRecordRepository(repository.WithDuplicateTitles(duplicates.Reject))
+		},
+		"SQLite": func(t *testing.T) repository.RecordRepositoryInterface {
+			return repository.NewRecordRepository(migratedSQLite(t), repository.WithDuplicateTitles(duplicates.Reject))
+		},
+	}
+
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			expectDuplicate := func(t *testing.T, err error, existingID int) {
+				t.Helper()
+				var dup *repository.DuplicateTitleError
+				if !errors.Is(err, repository.ErrDuplicateTitle) || !errors.As(err, &dup) || dup.ExistingID != existingID {
+					t.Errorf("Expected a duplicate of record %d, got %v", existingID, err)
+				}
+			}
+
+			blueLock := models.Record{Title: "Blue Lock", TotalEpisodes: 24, Type: "tv", Status: "planning"}
+			if err := repo.CreateRecord(ctx, &blueLock); err != nil {
+				t.Fatalf("Failed to create record: %v", err)
+			}
+			monster := models.Record{Title: "Monster", TotalEpisodes: 74, Type: "tv", Status: "planning"}
+			if err := repo.CreateRecord(ctx, &monster); err != nil {
+				t.Fatalf("Failed to create record: %v", err)
+			}
+
+			t.Run("Create", func(t *testing.T) {
+				expectDuplicate(t, repo.CreateRecord(ctx, &models.Record{Title: "blue lock!", Type: "tv", Status: "planning"}), blueLock.ID)
+			})
+
+			t.Run("Batch", func(t *testing.T) {
+				results, err := repo.ApplyBatch(ctx, []repository.BatchOperation{
+					{Op: repository.BatchCreate, Record: models.Record{Title: "Baccano!", Type: "tv", Status: "planning"}},
+					{Op: repository.BatchCreate, Record: models.Record{Title: "BLUE LOCK", Type: "tv", Status: "planning"}},
+				}, false)
+				if err != nil {
+					t.Fatalf("Failed to apply batch: %v", err)
+				}
+				if results[0].Err != nil {
+					t.Errorf("Expected a new title to be created, got %v", results[0].Err)
+				}
+				expectDuplicate(t, results[1].Err, blueLock.ID)
+			})
+
+			t.Run("Update", func(t *testing.T) {
+				record := monster
+				record.Title = "Blue-Lock"
+				expectDuplicate(t, repo.UpdateRecord(ctx, &record), blueLock.ID)
+
+				// Keeping the title is not a duplicate of the record itself.
+				record.Title = "MONSTER"
+				if err := repo.UpdateRecord(ctx, &record); err != nil {
+					t.Errorf("Expected a record to keep its title, got %v", err)
+				}
+			})
+
+			t.Run("Patch", func(t *testing.T) {
+				title := "blue lock"
+				_, err := repo.PatchRecord(ctx, monster.ID, models.RecordPatch{Title: &title})
+				expectDuplicate(t, err, blueLock.ID)
+			})
+
+			t.Run("No letters or digits", func(t *testing.T) {
+				// These titles all have an empty key, which is not a
+				// duplicate of anything.
+				for _, title := range []string{"!!!", "???", "..."} {
+					record := models.Record{Title: title, Type: "movie", Status: "planning"}
+					if err := repo.CreateRecord(ctx, &record); err != nil {
+						t.Errorf("Expected %q to be created, got %v", title, err)
+					}
+				}
+				if records, err := repo.FindByTitle(ctx, "!!!"); err != nil || len(records) != 0 {
+					t.Errorf("Expected no matches for an empty key, got %+v (%v)", records, err)
+				}
+			})
+
+			t.Run("Trash", func(t *testing.T) {
+				if err := repo.DeleteRecord(ctx, blueLock.ID); err != nil {
+					t.Fatalf("Failed to delete record: %v", err)
+				}
+				again := models.Record{Title: "Blue Lock", Type: "tv", Status: "watching"}
+				if err := repo.CreateRecord(ctx, &again); err != nil {
+					t.Fatalf("Expected a trashed title to be free, got %v", err)
+				}
+
+				_, err := repo.RestoreRecord(ctx, blueLock.ID)
+				expectDuplicate(t, err, again.ID)
+				if trash, _ := repo.ListTrash(ctx); len(trash) != 1 || trash[0].ID != blueLock.ID {
+					t.Errorf("Expected the record to stay in the trash, got %+v", trash)
+				}
+
+				if _, err := repo.MergeRecords(ctx, again.ID, monster.ID); err != nil {
+					t.Fatalf("Failed to merge records: %v", err)
+				}
+				if _, err := repo.RestoreRecord(ctx, monster.ID); err != nil {
+					t.Errorf("Expected a merged away title to be restorable, got %v", err)
+				}
+			})
+		})
+	}
+
+	t.Run("Unique index", func(t *testing.T) {
+		// A title claimed by a write the check did not see, as when two
+		// creates race, is still caught by the unique index.
+		database := migratedSQLite(t)
+		_, err := database.Exec(`INSERT INTO watch_list (title, unique_title_key, total_episodes, watched_episodes, type, status)
+			VALUES ('Blue Lock', 'blue lock', 24, 0, 'tv', 'planning')`)
+		if err != nil {
+			t.Fatalf("Failed to insert record: %v", err)
+		}
+		repo := repository.NewRecordRepository(database, repository.WithDuplicateTitles(duplicates.Reject))
+		err = repo.CreateRecord(context.Background(), &models.Record{Title: "blue lock", Type: "tv", Status: "planning"})
+		if !errors.Is(err, repository.ErrDuplicateTitle) {
+			t.Errorf("Expected ErrDuplicateTitle, got %v", err)
+		}
+	})
+}
+
+// migratedSQLite returns a migrated in-memory SQLite database that is
+// closed when t ends.
+func migratedSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := db.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("Failed to migrate SQLite database: %v", err)
+	}
+	return database
+}
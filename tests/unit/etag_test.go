@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestETags_Mock(t *testing.T) {
+	stored := models.Record{ID: 1, Title: "Potemayo", TotalEpisodes: 12, WatchedEpisodes: 2, Type: "tv", Status: "watching", Version: 3}
+
+	var updatedWith, deletedWith int
+	mockRepo := &mockRecordRepository{
+		getByIDFunc: func(ctx context.Context, id int) (*models.Record, error) {
+			record := stored
+			return &record, nil
+		},
+		updateFunc: func(ctx context.Context, record *models.Record) error {
+			updatedWith = record.Version
+			if record.Version > 0 && record.Version != stored.Version {
+				return &repository.Error{Op: "update record", ID: record.ID, Kind: repository.ErrVersionMismatch}
+			}
+			record.Version = stored.Version + 1
+			return nil
+		},
+		deleteFunc: func(ctx context.Context, id int) error {
+			deletedWith = 0
+			return nil
+		},
+		deleteVFunc: func(ctx context.Context, id int, version int) error {
+			deletedWith = version
+			if version != stored.Version {
+				return &repository.Error{Op: "delete record", ID: id, Kind: repository.ErrVersionMismatch}
+			}
+			return nil
+		},
+	}
+	router := mux.NewRouter()
+	routes.RegisterRecordRoutes(router, mockRepo)
+
+	serve := func(method, target string, headers map[string]string) *httptest.ResponseRecorder {
+		var body []byte
+		if method == http.MethodPut {
+			body, _ = json.Marshal(stored)
+		}
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("GET returns the version as ETag", func(t *testing.T) {
+		w := serve(http.MethodGet, "/watchlist/1", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if etag := w.Header().Get("ETag"); etag != `"3"` {
+			t.Errorf("Expected ETag %q, got %q", `"3"`, etag)
+		}
+	})
+
+	t.Run("GET with matching If-None-Match is not modified", func(t *testing.T) {
+		w := serve(http.MethodGet, "/watchlist/1", map[string]string{"If-None-Match": `W/"3"`})
+		if w.Code != http.StatusNotModified {
+			t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected empty body, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("GET with stale If-None-Match returns the record", func(t *testing.T) {
+		w := serve(http.MethodGet, "/watchlist/1", map[string]string{"If-None-Match": `"2"`})
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("PUT with current If-Match", func(t *testing.T) {
+		w := serve(http.MethodPut, "/watchlist/1", map[string]string{"If-Match": `"3"`})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if updatedWith != 3 {
+			t.Errorf("Expected update conditional on version 3, got %d", updatedWith)
+		}
+		if etag := w.Header().Get("ETag"); etag != `"4"` {
+			t.Errorf("Expected ETag %q, got %q", `"4"`, etag)
+		}
+	})
+
+	t.Run("PUT with stale If-Match", func(t *testing.T) {
+		w := serve(http.MethodPut, "/watchlist/1", map[string]string{"If-Match": `"2"`})
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+		}
+	})
+
+	t.Run("PUT without If-Match ignores the body version", func(t *testing.T) {
+		w := serve(http.MethodPut, "/watchlist/1", nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if updatedWith != 0 {
+			t.Errorf("Expected unconditional update, got version %d", updatedWith)
+		}
+	})
+
+	t.Run("Weak If-Match never matches", func(t *testing.T) {
+		w := serve(http.MethodPut, "/watchlist/1", map[string]string{"If-Match": `W/"3"`})
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+		}
+	})
+
+	t.Run("DELETE with stale If-Match", func(t *testing.T) {
+		w := serve(http.MethodDelete, "/watchlist/1", map[string]string{"If-Match": `"1"`})
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+		}
+		if deletedWith != 1 {
+			t.Errorf("Expected delete conditional on version 1, got %d", deletedWith)
+		}
+	})
+
+	t.Run("DELETE with current If-Match", func(t *testing.T) {
+		w := serve(http.MethodDelete, "/watchlist/1", map[string]string{"If-Match": `"3"`})
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"golang-watchlist/internal/trash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHistoryRoutes(t *testing.T) {
+	repo := repository.NewMemoryRecordRepository()
+	router := mux.NewRouter()
+	routes.RegisterRecordRoutes(router, repo)
+
+	body, _ := json.Marshal(models.Record{Title: "Ping Pong the Animation", TotalEpisodes: 11, Type: "tv", Status: "planning"})
+	req := httptest.NewRequest(http.MethodPost, "/watchlist", bytes.NewReader(body))
+	req.Header.Set("X-Actor", "alice")
+	req.Header.Set("X-Request-ID", "import-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if id := w.Header().Get("X-Request-ID"); id != "import-42" {
+		t.Errorf("Expected request ID to be echoed, got %q", id)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/watchlist/1/progress", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	generated := w.Header().Get("X-Request-ID")
+	if len(generated) != 32 {
+		t.Errorf("Expected a generated request ID, got %q", generated)
+	}
+
+	t.Run("Timeline", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/1/history", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var history []models.HistoryEntry
+		if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(history) != 2 {
+			t.Fatalf("Expected 2 entries, got %+v", history)
+		}
+		if e := history[0]; e.Action != models.ActionCreate || e.Actor != "alice" || e.RequestID != "import-42" || e.Before != nil || e.After == nil {
+			t.Errorf("Unexpected create entry %+v", e)
+		}
+		if e := history[1]; e.Action != models.ActionUpdate || e.Actor != "" || e.RequestID != generated || e.After.WatchedEpisodes != 1 {
+			t.Errorf("Unexpected progress entry %+v", e)
+		}
+	})
+
+	t.Run("Missing record", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/99/history", nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("Invalid ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/abc/history", nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("Invalid actor", func(t *testing.T) {
+		for _, actor := range []string{strings.Repeat("a", 129), "alice\x1b[2J", "alice\u0085"} {
+			req := httptest.NewRequest(http.MethodPost, "/watchlist/1/progress", nil)
+			req.Header.Set("X-Actor", actor)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assertProblem(t, w, http.StatusBadRequest, routes.CodeValidation)
+		}
+
+		history, _ := repo.GetRecordHistory(context.Background(), 1)
+		if len(history) != 2 {
+			t.Errorf("Expected the rejected requests not to change the record, got %+v", history)
+		}
+	})
+}
+
+func TestTrashPurgerActor(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository()
+	record := models.Record{Title: "Kino's Journey", Type: "tv", Status: "completed"}
+	if err := repo.CreateRecord(ctx, &record); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	if err := repo.DeleteRecord(ctx, record.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+
+	purger := &trash.Purger{Store: repo, Retention: time.Hour, Interval: time.Hour}
+	if _, err := purger.PurgeOnce(ctx, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+
+	history, err := repo.GetRecordHistory(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if last := history[len(history)-1]; last.Action != models.ActionPurge || last.Actor != trash.Actor {
+		t.Errorf("Expected a purge by %q, got %+v", trash.Actor, last)
+	}
+}
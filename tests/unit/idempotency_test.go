@@ -0,0 +1,329 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/duplicates"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func postWithKey(router *mux.Router, key string, record models.Record) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(record)
+	req := httptest.NewRequest(http.MethodPost, "/watchlist", bytes.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	var problem routes.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if problem.Code != code {
+		t.Errorf("Expected error code %s, got %s", code, problem.Code)
+	}
+}
+
+func TestIdempotencyRoutes(t *testing.T) {
+	record := models.Record{Title: "Dennou Coil", TotalEpisodes: 26, Type: "tv", Status: "planning"}
+
+	t.Run("Replay", func(t *testing.T) {
+		repo := repository.NewMemoryRecordRepository()
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo)
+
+		first := postWithKey(router, "retry-1", record)
+		if first.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, first.Code, first.Body.String())
+		}
+		retry := postWithKey(router, "retry-1", record)
+		if retry.Code != http.StatusCreated {
+			t.Fatalf("Expected replayed status %d, got %d", http.StatusCreated, retry.Code)
+		}
+		if retry.Body.String() != first.Body.String() {
+			t.Errorf("Expected replayed body %s, got %s", first.Body.String(), retry.Body.String())
+		}
+		if retry.Header().Get("ETag") != first.Header().Get("ETag") || retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("Unexpected replay headers %v", retry.Header())
+		}
+		if first.Header().Get("Idempotent-Replayed") != "" {
+			t.Error("Expected the original response not to be marked as replayed")
+		}
+
+		records, _ := repo.GetRecords(context.Background())
+		if len(records) != 1 {
+			t.Errorf("Expected 1 record, got %d", len(records))
+		}
+	})
+
+	t.Run("DifferentBody", func(t *testing.T) {
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repository.NewMemoryRecordRepository())
+
+		postWithKey(router, "retry-1", record)
+		changed := record
+		changed.Status = "watching"
+		w := postWithKey(router, "retry-1", changed)
+		assertProblem(t, w, http.StatusUnprocessableEntity, routes.CodeIdempotencyKeyReused)
+	})
+
+	t.Run("WithoutKey", func(t *testing.T) {
+		repo := repository.NewMemoryRecordRepository()
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo)
+
+		postWithKey(router, "", record)
+		postWithKey(router, "", record)
+		records, _ := repo.GetRecords(context.Background())
+		if len(records) != 2 {
+			t.Errorf("Expected 2 records, got %d", len(records))
+		}
+	})
+
+	t.Run("KeyTooLong", func(t *testing.T) {
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repository.NewMemoryRecordRepository())
+
+		w := postWithKey(router, strings.Repeat("k", 256), record)
+		assertProblem(t, w, http.StatusBadRequest, routes.CodeValidation)
+	})
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		repo := repository.NewMemoryRecordRepository()
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo)
+
+		big := record
+		big.Title = strings.Repeat("a", 2<<20)
+		w := postWithKey(router, "retry-1", big)
+		assertProblem(t, w, http.StatusRequestEntityTooLarge, routes.CodeValidation)
+	})
+
+	t.Run("InProgress", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		repo := &mockRecordRepository{createFunc: func(ctx context.Context, r *models.Record) error {
+			close(started)
+			<-release
+			r.ID, r.Version = 1, 1
+			return nil
+		}}
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo, routes.WithDuplicatePolicy(duplicates.Allow))
+
+		original := make(chan *httptest.ResponseRecorder)
+		go func() { original <- postWithKey(router, "retry-1", record) }()
+		<-started
+
+		w := postWithKey(router, "retry-1", record)
+		assertProblem(t, w, http.StatusConflict, routes.CodeConflict)
+
+		close(release)
+		if w := <-original; w.Code != http.StatusCreated {
+			t.Errorf("Expected the original request to finish with %d, got %d", http.StatusCreated, w.Code)
+		}
+	})
+
+	t.Run("LeaseRunsOut", func(t *testing.T) {
+		calls := 0
+		started := make(chan struct{})
+		release := make(chan struct{})
+		repo := &mockRecordRepository{createFunc: func(ctx context.Context, r *models.Record) error {
+			calls++
+			if calls == 1 {
+				close(started)
+				<-release
+			}
+			r.ID, r.Version = calls, 1
+			return nil
+		}}
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo,
+			routes.WithDuplicatePolicy(duplicates.Allow), routes.WithIdempotencyLease(10*time.Millisecond))
+
+		original := make(chan *httptest.ResponseRecorder)
+		go func() { original <- postWithKey(router, "retry-1", record) }()
+		<-started
+		time.Sleep(20 * time.Millisecond)
+
+		w := postWithKey(router, "retry-1", record)
+		if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("Expected a retry after the lease to take the key over, got %d %v", w.Code, w.Header())
+		}
+		close(release)
+		<-original
+	})
+
+	t.Run("ServerErrorNotStored", func(t *testing.T) {
+		fail := true
+		repo := &mockRecordRepository{createFunc: func(ctx context.Context, r *models.Record) error {
+			if fail {
+				return repository.ErrUnavailable
+			}
+			r.ID, r.Version = 1, 1
+			return nil
+		}}
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo, routes.WithDuplicatePolicy(duplicates.Allow))
+
+		if w := postWithKey(router, "retry-1", record); w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+		fail = false
+		w := postWithKey(router, "retry-1", record)
+		if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("Expected the retry to run again, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("PanicReleasesKey", func(t *testing.T) {
+		fail := true
+		repo := &mockRecordRepository{createFunc: func(ctx context.Context, r *models.Record) error {
+			if fail {
+				panic("boom")
+			}
+			r.ID, r.Version = 1, 1
+			return nil
+		}}
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo, routes.WithDuplicatePolicy(duplicates.Allow))
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected the handler panic to propagate")
+				}
+			}()
+			postWithKey(router, "retry-1", record)
+		}()
+		fail = false
+		w := postWithKey(router, "retry-1", record)
+		if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("Expected the retry to run again, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("DifferentQuery", func(t *testing.T) {
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repository.NewMemoryRecordRepository())
+
+		postWithKey(router, "retry-1", record)
+		body, _ := json.Marshal(record)
+		req := httptest.NewRequest(http.MethodPost, "/watchlist?auto_status=false", bytes.NewReader(body))
+		req.Header.Set("Idempotency-Key", "retry-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assertProblem(t, w, http.StatusUnprocessableEntity, routes.CodeIdempotencyKeyReused)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		repo := repository.NewMemoryRecordRepository()
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo,
+			routes.WithIdempotency(repository.NewMemoryIdempotencyStore(), 10*time.Millisecond))
+
+		postWithKey(router, "retry-1", record)
+		time.Sleep(20 * time.Millisecond)
+		w := postWithKey(router, "retry-1", record)
+		if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("Expected an expired key to run again, got %d %v", w.Code, w.Header())
+		}
+		records, _ := repo.GetRecords(context.Background())
+		if len(records) != 2 {
+			t.Errorf("Expected 2 records, got %d", len(records))
+		}
+	})
+}
+
+func TestIdempotencyStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) repository.IdempotencyStore{
+		"Memory": func(t *testing.T) repository.IdempotencyStore {
+			return repository.NewMemoryIdempotencyStore()
+		},
+		"SQLite": func(t *testing.T) repository.IdempotencyStore {
+			database, err := db.OpenSQLite(":memory:")
+			if err != nil {
+				t.Fatalf("Failed to open SQLite database: %v", err)
+			}
+			t.Cleanup(func() { database.Close() })
+			if err := db.Migrate(database); err != nil {
+				t.Fatalf("Failed to migrate SQLite database: %v", err)
+			}
+			return repository.NewIdempotencyStore(database)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			expires := time.Now().Add(time.Hour)
+
+			stored, err := store.Reserve(ctx, "key-1", "hash-1", expires)
+			if err != nil || stored != nil {
+				t.Fatalf("Expected to reserve a new key, got %+v, %v", stored, err)
+			}
+			stored, err = store.Reserve(ctx, "key-1", "hash-1", expires)
+			if err != nil || stored == nil || stored.Status != 0 || stored.RequestHash != "hash-1" {
+				t.Fatalf("Expected an in-flight entry, got %+v, %v", stored, err)
+			}
+
+			header := map[string]string{"Content-Type": "application/json"}
+			if err := store.Complete(ctx, "key-1", http.StatusCreated, header, []byte(`{"id":1}`), expires); err != nil {
+				t.Fatalf("Complete failed: %v", err)
+			}
+			stored, err = store.Reserve(ctx, "key-1", "hash-2", expires)
+			if err != nil || stored == nil {
+				t.Fatalf("Expected the stored response, got %+v, %v", stored, err)
+			}
+			if stored.Status != http.StatusCreated || stored.RequestHash != "hash-1" ||
+				string(stored.Body) != `{"id":1}` || stored.Header["Content-Type"] != "application/json" {
+				t.Errorf("Unexpected stored response %+v", stored)
+			}
+
+			if err := store.Release(ctx, "key-1"); err != nil {
+				t.Fatalf("Release failed: %v", err)
+			}
+			if stored, err := store.Reserve(ctx, "key-1", "hash-2", expires); err != nil || stored != nil {
+				t.Errorf("Expected a released key to be reserved again, got %+v, %v", stored, err)
+			}
+
+			if _, err := store.Reserve(ctx, "key-2", "hash-1", time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("Reserve failed: %v", err)
+			}
+			if stored, err := store.Reserve(ctx, "key-2", "hash-1", expires); err != nil || stored != nil {
+				t.Errorf("Expected an abandoned claim to be taken over, got %+v, %v", stored, err)
+			}
+
+			// Completing a claim keeps the response past the lease.
+			if _, err := store.Reserve(ctx, "key-3", "hash-1", time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("Reserve failed: %v", err)
+			}
+			if err := store.Complete(ctx, "key-3", http.StatusCreated, header, []byte(`{"id":3}`), expires); err != nil {
+				t.Fatalf("Complete failed: %v", err)
+			}
+			if stored, err := store.Reserve(ctx, "key-3", "hash-1", expires); err != nil || stored == nil || stored.Status != http.StatusCreated {
+				t.Errorf("Expected the completed response to outlive the lease, got %+v, %v", stored, err)
+			}
+		})
+	}
+}
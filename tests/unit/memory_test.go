@@ -0,0 +1,197 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"sync"
+	"testing"
+)
+
+func TestMemoryRecordRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository()
+
+	first := models.Record{Title: "Mushishi", TotalEpisodes: 26, Type: "TV", Status: "planning"}
+	if err := repo.CreateRecord(ctx, &first); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	if first.ID != 1 || first.Version != 1 || first.Type != "tv" {
+		t.Errorf("Expected normalized record with ID 1 and version 1, got %+v", first)
+	}
+
+	if err := repo.DeleteRecord(ctx, first.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	second := models.Record{Title: "Mushishi", TotalEpisodes: 26, Type: "tv", Status: "planning"}
+	if err := repo.CreateRecord(ctx, &second); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Errorf("Expected deleted ID %d not to be reused", first.ID)
+	}
+
+	invalid := models.Record{Title: "", Status: "unknown"}
+	err := repo.CreateRecord(ctx, &invalid)
+	var validationErr *models.ValidationError
+	if !errors.Is(err, repository.ErrValidation) || !errors.As(err, &validationErr) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	got, err := repo.GetRecordByID(ctx, second.ID)
+	if err != nil || got.Title != "Mushishi" {
+		t.Fatalf("Expected stored record, got %+v, %v", got, err)
+	}
+	got.Title = "Changed"
+	if again, _ := repo.GetRecordByID(ctx, second.ID); again.Title != "Mushishi" {
+		t.Error("Expected returned records to be copies")
+	}
+
+	stale := second
+	second.WatchedEpisodes = 3
+	second.Status = "watching"
+	if err := repo.UpdateRecord(ctx, &second); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if second.Version != 2 {
+		t.Errorf("Expected version 2, got %d", second.Version)
+	}
+	if err := repo.UpdateRecord(ctx, &stale); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch from stale update, got %v", err)
+	}
+
+	title := "Mushi-shi"
+	patched, err := repo.PatchRecord(ctx, second.ID, models.RecordPatch{Title: &title})
+	if err != nil {
+		t.Fatalf("Failed to patch record: %v", err)
+	}
+	if patched.Title != title || patched.WatchedEpisodes != 3 || patched.Version != 3 {
+		t.Errorf("Expected only the title to change, got %+v", patched)
+	}
+
+	progressed, err := repo.IncrementProgress(ctx, second.ID, 100)
+	if err != nil {
+		t.Fatalf("Failed to increment progress: %v", err)
+	}
+	if progressed.WatchedEpisodes != 26 {
+		t.Errorf("Expected progress clamped to 26, got %d", progressed.WatchedEpisodes)
+	}
+	progressed, _ = repo.IncrementProgress(ctx, second.ID, -100)
+	if progressed.WatchedEpisodes != 0 {
+		t.Errorf("Expected progress clamped to 0, got %d", progressed.WatchedEpisodes)
+	}
+
+	if err := repo.DeleteRecordVersion(ctx, second.ID, 1); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch from stale delete, got %v", err)
+	}
+	if err := repo.DeleteRecordVersion(ctx, second.ID, progressed.Version); err != nil {
+		t.Errorf("Failed to delete current version: %v", err)
+	}
+}
+
+func TestMemoryRecordRepositoryNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository()
+	missing := models.Record{ID: 42, Title: "Missing", Type: "tv", Status: "planning"}
+	title := "Missing"
+
+	checks := map[string]error{}
+	_, checks["get"] = repo.GetRecordByID(ctx, 42)
+	checks["update"] = repo.UpdateRecord(ctx, &missing)
+	_, checks["patch"] = repo.PatchRecord(ctx, 42, models.RecordPatch{Title: &title})
+	_, checks["progress"] = repo.IncrementProgress(ctx, 42, 1)
+	checks["delete"] = repo.DeleteRecord(ctx, 42)
+	checks["delete version"] = repo.DeleteRecordVersion(ctx, 42, 1)
+
+	for name, err := range checks {
+		var repoErr *repository.Error
+		if !errors.Is(err, repository.ErrRecordNotFound) || !errors.As(err, &repoErr) || repoErr.ID != 42 {
+			t.Errorf("Expected ErrRecordNotFound for ID 42 from %s, got %v", name, err)
+		}
+	}
+
+	records, err := repo.GetRecords(ctx)
+	if err != nil || records != nil {
+		t.Errorf("Expected no records, got %v, %v", records, err)
+	}
+}
+
+func TestMemoryListRecords(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository()
+	for _, record := range []models.Record{
+		{Title: "Naruto", TotalEpisodes: 220, WatchedEpisodes: 220, Type: "tv", Status: "completed"},
+		{Title: "Akira", TotalEpisodes: 1, Type: "movie", Status: "planning"},
+		{Title: "Naruto Shippuden", TotalEpisodes: 500, WatchedEpisodes: 10, Type: "tv", Status: "watching"},
+		{Title: "Boruto", TotalEpisodes: 293, WatchedEpisodes: 10, Type: "tv", Status: "dropped"},
+	} {
+		if err := repo.CreateRecord(ctx, &record); err != nil {
+			t.Fatalf("Failed to create record: %v", err)
+		}
+	}
+
+	page, err := repo.ListRecords(ctx, repository.ListOptions{Type: "tv", TitleContains: "NARUTO"})
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+	if len(page.Records) != 2 || page.NextCursor != "" {
+		t.Errorf("Expected 2 matching records and no next page, got %+v", page)
+	}
+
+	opts := repository.ListOptions{SortBy: "watched_episodes", Descending: true, Limit: 2}
+	var ids []int
+	for {
+		page, err := repo.ListRecords(ctx, opts)
+		if err != nil {
+			t.Fatalf("Failed to list records: %v", err)
+		}
+		for _, record := range page.Records {
+			ids = append(ids, record.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	expected := []int{1, 4, 3, 2}
+	if len(ids) != len(expected) {
+		t.Fatalf("Expected ids %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("Expected ids %v, got %v", expected, ids)
+		}
+	}
+
+	_, err = repo.ListRecords(ctx, repository.ListOptions{SortBy: "title", Cursor: "bogus"})
+	if !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestMemoryConcurrentProgress(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository()
+	record := models.Record{Title: "One Piece", TotalEpisodes: 1000, Type: "tv", Status: "watching"}
+	if err := repo.CreateRecord(ctx, &record); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.IncrementProgress(ctx, record.ID, 1); err != nil {
+				t.Errorf("Failed to increment progress: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := repo.GetRecordByID(ctx, record.ID)
+	if got.WatchedEpisodes != 50 || got.Version != 51 {
+		t.Errorf("Expected 50 episodes at version 51, got %d at version %d", got.WatchedEpisodes, got.Version)
+	}
+}
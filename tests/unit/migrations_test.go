@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"context"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/migrations"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"0002_add_index.up.sql":      {Data: []byte("CREATE INDEX idx ON t (c);")},
+		"0002_add_index.down.sql":    {Data: []byte("DROP INDEX idx ON t;")},
+		"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (c INTEGER);")},
+		"0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+
+	loaded, err := migrations.Load(files)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Version != 1 || loaded[1].Version != 2 {
+		t.Fatalf("Expected migrations 1 and 2 in order, got %+v", loaded)
+	}
+	if loaded[0].Name != "create_table" || !strings.Contains(loaded[0].Down, "DROP TABLE") {
+		t.Errorf("Unexpected first migration %+v", loaded[0])
+	}
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "Missing down file",
+			files: fstest.MapFS{"0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (c INTEGER);")}},
+		},
+		{
+			name:  "Unexpected file name",
+			files: fstest.MapFS{"create_table.sql": {Data: []byte("CREATE TABLE t (c INTEGER);")}},
+		},
+		{
+			name: "Conflicting names for one version",
+			files: fstest.MapFS{
+				"0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (c INTEGER);")},
+				"0001_make_table.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := migrations.Load(tt.files); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	var versions []int
+	for _, dialect := range []migrations.Dialect{migrations.MySQL, migrations.SQLite, migrations.Postgres} {
+		t.Run(dialect.Name, func(t *testing.T) {
+			loaded, err := migrations.Load(dialect.Files)
+			if err != nil {
+				t.Fatalf("Failed to load embedded migrations: %v", err)
+			}
+			for i, m := range loaded {
+				if m.Version != i+1 {
+					t.Errorf("Expected contiguous versions, got %d at position %d", m.Version, i)
+				}
+			}
+			if versions != nil && len(loaded) != len(versions) {
+				t.Errorf("Expected %d migrations like the other dialects, got %d", len(versions), len(loaded))
+			}
+			versions = make([]int, len(loaded))
+		})
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	database, err := db.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer database.Close()
+
+	migrate := func(second string) ([]migrations.Migration, error) {
+		dialect := migrations.SQLite
+		dialect.Files = fstest.MapFS{
+			"0001_create_shows.up.sql":     {Data: []byte("CREATE TABLE shows (id INTEGER);")},
+			"0001_create_shows.down.sql":   {Data: []byte("DROP TABLE shows;")},
+			"0002_create_seasons.up.sql":   {Data: []byte(second)},
+			"0002_create_seasons.down.sql": {Data: []byte("DROP TABLE seasons;")},
+		}
+		migrator, err := migrations.NewMigrator(database, dialect)
+		if err != nil {
+			t.Fatalf("Failed to create migrator: %v", err)
+		}
+		return migrator.Up(context.Background())
+	}
+
+	if _, err := migrate("CREATE TABLE seasons (id INTEGER); INSERT INTO episodes VALUES (1);"); err == nil {
+		t.Fatal("Expected the second migration to fail")
+	}
+	var tables int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('shows', 'seasons', 'schema_migrations')`).Scan(&tables); err != nil {
+		t.Fatalf("Failed to list tables: %v", err)
+	}
+	if tables != 0 {
+		t.Errorf("Expected the failed run to leave no tables behind, found %d", tables)
+	}
+
+	applied, err := migrate("CREATE TABLE seasons (id INTEGER);")
+	if err != nil {
+		t.Fatalf("Expected the fixed migrations to apply cleanly: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("Expected both migrations to be applied, got %+v", applied)
+	}
+}
+
+func TestStatusIsReadOnly(t *testing.T) {
+	database, err := db.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer database.Close()
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	ctx := context.Background()
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed on an empty database: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Errorf("Expected %d_%s to be pending", status.Version, status.Name)
+		}
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %d (%v)", version, err)
+	}
+	var tables int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'`).Scan(&tables); err != nil {
+		t.Fatalf("Failed to list tables: %v", err)
+	}
+	if tables != 0 {
+		t.Error("Expected Status to leave the database untouched")
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != applied[len(applied)-1].Version {
+		t.Errorf("Expected version %d, got %d (%v)", applied[len(applied)-1].Version, version, err)
+	}
+}
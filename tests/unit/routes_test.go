@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"golang-watchlist/internal/db"
 	"golang-watchlist/internal/models"
 	"golang-watchlist/internal/repository"
 	"golang-watchlist/internal/routes"
@@ -15,7 +16,10 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -29,10 +33,23 @@ var testDB *sql.DB
 // ====================================================================================================
 
 type mockRecordRepository struct {
-	createFunc func(ctx context.Context, record *models.Record) error
-	getFunc    func(ctx context.Context) ([]models.Record, error)
-	updateFunc func(ctx context.Context, record *models.Record) error
-	deleteFunc func(ctx context.Context, id int) error
+	createFunc   func(ctx context.Context, record *models.Record) error
+	getFunc      func(ctx context.Context) ([]models.Record, error)
+	listFunc     func(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error)
+	getByIDFunc  func(ctx context.Context, id int) (*models.Record, error)
+	updateFunc   func(ctx context.Context, record *models.Record) error
+	patchFunc    func(ctx context.Context, id int, patch models.RecordPatch) (*models.Record, error)
+	progressFunc func(ctx context.Context, id int, delta int) (*models.Record, error)
+	deleteFunc   func(ctx context.Context, id int) error
+	deleteVFunc  func(ctx context.Context, id int, version int) error
+	trashFunc    func(ctx context.Context) ([]models.TrashedRecord, error)
+	restoreFunc  func(ctx context.Context, id int) (*models.Record, error)
+	purgeFunc    func(ctx context.Context, before time.Time) (int, error)
+	historyFunc  func(ctx context.Context, id int) ([]models.HistoryEntry, error)
+	batchFunc    func(ctx context.Context, ops []repository.BatchOperation, atomic bool) ([]repository.BatchResult, error)
+	mergeFunc    func(ctx context.Context, keepID, removeID int) (*models.Record, error)
+	titleFunc    func(ctx context.Context, title string) ([]models.Record, error)
+	snapshotFunc func(ctx context.Context, fn func(models.TrashedRecord) error) error
 }
 
 func (m *mockRecordRepository) CreateRecord(ctx context.Context, record *models.Record) error {
@@ -43,14 +60,66 @@ func (m *mockRecordRepository) GetRecords(ctx context.Context) ([]models.Record,
 	return m.getFunc(ctx)
 }
 
+func (m *mockRecordRepository) ListRecords(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error) {
+	return m.listFunc(ctx, opts)
+}
+
+func (m *mockRecordRepository) GetRecordByID(ctx context.Context, id int) (*models.Record, error) {
+	return m.getByIDFunc(ctx, id)
+}
+
 func (m *mockRecordRepository) UpdateRecord(ctx context.Context, record *models.Record) error {
 	return m.updateFunc(ctx, record)
 }
 
+func (m *mockRecordRepository) PatchRecord(ctx context.Context, id int, patch models.RecordPatch) (*models.Record, error) {
+	return m.patchFunc(ctx, id, patch)
+}
+
+func (m *mockRecordRepository) IncrementProgress(ctx context.Context, id int, delta int) (*models.Record, error) {
+	return m.progressFunc(ctx, id, delta)
+}
+
 func (m *mockRecordRepository) DeleteRecord(ctx context.Context, id int) error {
 	return m.deleteFunc(ctx, id)
 }
 
+func (m *mockRecordRepository) DeleteRecordVersion(ctx context.Context, id int, version int) error {
+	return m.deleteVFunc(ctx, id, version)
+}
+
+func (m *mockRecordRepository) ListTrash(ctx context.Context) ([]models.TrashedRecord, error) {
+	return m.trashFunc(ctx)
+}
+
+func (m *mockRecordRepository) RestoreRecord(ctx context.Context, id int) (*models.Record, error) {
+	return m.restoreFunc(ctx, id)
+}
+
+func (m *mockRecordRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	return m.purgeFunc(ctx, before)
+}
+
+func (m *mockRecordRepository) GetRecordHistory(ctx context.Context, id int) ([]models.HistoryEntry, error) {
+	return m.historyFunc(ctx, id)
+}
+
+func (m *mockRecordRepository) ApplyBatch(ctx context.Context, ops []repository.BatchOperation, atomic bool) ([]repository.BatchResult, error) {
+	return m.batchFunc(ctx, ops, atomic)
+}
+
+func (m *mockRecordRepository) MergeRecords(ctx context.Context, keepID, removeID int) (*models.Record, error) {
+	return m.mergeFunc(ctx, keepID, removeID)
+}
+
+func (m *mockRecordRepository) FindByTitle(ctx context.Context, title string) ([]models.Record, error) {
+	return m.titleFunc(ctx, title)
+}
+
+func (m *mockRecordRepository) Snapshot(ctx context.Context, fn func(models.TrashedRecord) error) error {
+	return m.snapshotFunc(ctx, fn)
+}
+
 var _ repository.RecordRepositoryInterface = &mockRecordRepository{}
 
 // ====================================================================================================
@@ -82,36 +151,41 @@ func TestMain(m *testing.M) {
 }
 
 func setupTestDB() (*sql.DB, error) {
-	dbUser := os.Getenv("TEST_MYSQL_USER")
-	dbPassword := os.Getenv("TEST_MYSQL_PASSWORD")
-	dbHost := os.Getenv("TEST_MYSQL_HOST")
-	dbPort := os.Getenv("TEST_MYSQL_PORT")
-	dbName := os.Getenv("TEST_MYSQL_DATABASE")
+	switch os.Getenv("DB_DRIVER") {
+	case db.SQLite:
+		return setupMigratedTestDB(db.OpenSQLite(":memory:"))
+	case db.Postgres:
+		return setupMigratedTestDB(db.OpenPostgres(os.Getenv("TEST_POSTGRES_URL")))
+	}
 
-	var err error
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=UTF8mb4&parseTime=True&loc=Local", dbUser, dbPassword, dbHost, dbPort, dbName)
-	testDB, err = sql.Open("mysql", dsn)
+	port, err := strconv.Atoi(os.Getenv("TEST_MYSQL_PORT"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TEST_MYSQL_PORT: %v", err)
+	}
+	return setupMigratedTestDB(db.OpenMySQL(db.MySQLConfig{
+		User:     os.Getenv("TEST_MYSQL_USER"),
+		Password: os.Getenv("TEST_MYSQL_PASSWORD"),
+		Host:     os.Getenv("TEST_MYSQL_HOST"),
+		Port:     port,
+		Database: os.Getenv("TEST_MYSQL_DATABASE"),
+	}))
+}
+
+// setupMigratedTestDB pings database and brings it up to date with the
+// real migrations, so every driver is tested against the same schema.
+func setupMigratedTestDB(database *sql.DB, err error) (*sql.DB, error) {
 	if err != nil {
-		return nil, fmt.Errorf("Failed to connect to database: %v", err)
+		return nil, err
 	}
+	testDB = database
 
 	if err := testDB.Ping(); err != nil {
+		testDB.Close()
 		return nil, fmt.Errorf("Failed to ping test database: %v", err)
 	}
-
-	schema := `
-	CREATE TABLE IF NOT EXISTS watch_list (
-		id SERIAL PRIMARY KEY,
-		title TEXT NOT NULL,
-		total_episodes INTEGER,
-		watched_episodes INTEGER,
-		type TEXT,
-		status TEXT NOT NULL
-	)`
-	_, err = testDB.Exec(schema)
-	if err != nil {
+	if err := db.Migrate(testDB); err != nil {
 		testDB.Close()
-		return nil, fmt.Errorf("Failed to create watch_list table: %v", err)
+		return nil, err
 	}
 
 	return testDB, nil
@@ -120,6 +194,9 @@ func setupTestDB() (*sql.DB, error) {
 func tearDownTestDB(db *sql.DB) {
 	if db != nil {
 		db.Exec("DROP TABLE IF EXISTS watch_list")
+		db.Exec("DROP TABLE IF EXISTS watch_list_history")
+		db.Exec("DROP TABLE IF EXISTS idempotency_keys")
+		db.Exec("DROP TABLE IF EXISTS schema_migrations")
 		db.Close()
 	}
 }
@@ -282,6 +359,212 @@ func TestGetRecord_Mock(t *testing.T) {
 	}
 }
 
+func TestListRecords_Mock(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		listFunc       func(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error)
+		expectedStatus int
+		expectedCursor string
+	}{
+		{
+			name:  "Filters, sort and limit are passed to the repository",
+			query: "?status=watching&type=tv&title=blue&sort=watched_episodes&order=desc&limit=2",
+			listFunc: func(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error) {
+				want := repository.ListOptions{
+					Status:        "watching",
+					Type:          "tv",
+					TitleContains: "blue",
+					SortBy:        "watched_episodes",
+					Descending:    true,
+					Limit:         2,
+				}
+				if opts != want {
+					return nil, fmt.Errorf("unexpected options %+v", opts)
+				}
+				return &repository.RecordPage{
+					Records:    []models.Record{{ID: 5, Title: "Blue Lock"}, {ID: 3, Title: "Blue Lock Season 2"}},
+					NextCursor: "next-page",
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCursor: "next-page",
+		},
+		{
+			name:           "Unknown sort field",
+			query:          "?sort=rating",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown status",
+			query:          "?status=watchng",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown type",
+			query:          "?type=series",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Status and type are normalized",
+			query: "?status=On-Hold&type=TV",
+			listFunc: func(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error) {
+				if opts.Status != models.StatusOnHold || opts.Type != models.TypeTV {
+					return nil, fmt.Errorf("unexpected options %+v", opts)
+				}
+				return &repository.RecordPage{}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Limit out of range",
+			query:          "?limit=1000",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "As of a moment in time",
+			query: "?as_of=2024-12-31T23:59:59Z",
+			listFunc: func(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error) {
+				if want := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC); !opts.AsOf.Equal(want) {
+					return nil, fmt.Errorf("expected as of %v, got %v", want, opts.AsOf)
+				}
+				return &repository.RecordPage{}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "As of a date",
+			query: "?as_of=2025-01-01&status=completed",
+			listFunc: func(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error) {
+				if want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !opts.AsOf.Equal(want) || opts.Status != "completed" {
+					return nil, fmt.Errorf("unexpected options %+v", opts)
+				}
+				return &repository.RecordPage{}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid as_of",
+			query:          "?as_of=last+year",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Invalid cursor",
+			query: "?cursor=garbage",
+			listFunc: func(ctx context.Context, opts repository.ListOptions) (*repository.RecordPage, error) {
+				return nil, repository.ErrInvalidCursor
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/watchlist"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockRepo := &mockRecordRepository{
+				listFunc: tt.listFunc,
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/watchlist", routes.GetRecords(mockRepo)).Methods(http.MethodGet)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if cursor := w.Header().Get("X-Next-Cursor"); cursor != tt.expectedCursor {
+				t.Errorf("Expected next cursor %q, got %q", tt.expectedCursor, cursor)
+			}
+			if tt.expectedCursor != "" && !strings.Contains(w.Header().Get("Link"), "cursor="+tt.expectedCursor) {
+				t.Errorf("Expected Link header to carry the cursor, got %q", w.Header().Get("Link"))
+			}
+		})
+	}
+}
+
+func TestGetRecordByID_Mock(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		getByIDFunc    func(ctx context.Context, id int) (*models.Record, error)
+		expectedStatus int
+		expectedErr    bool
+	}{
+		{
+			name: "Successful get",
+			id:   "1",
+			getByIDFunc: func(ctx context.Context, id int) (*models.Record, error) {
+				return &models.Record{
+					ID:              id,
+					Title:           "Bleach",
+					TotalEpisodes:   366,
+					WatchedEpisodes: 366,
+					Type:            "TV",
+					Status:          "completed",
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedErr:    false,
+		},
+		{
+			name: "Invalid ID",
+			id:   "invalid",
+			getByIDFunc: func(ctx context.Context, id int) (*models.Record, error) {
+				return nil, nil
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedErr:    true,
+		},
+		{
+			name: "Record not found",
+			id:   "999",
+			getByIDFunc: func(ctx context.Context, id int) (*models.Record, error) {
+				return nil, repository.ErrRecordNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedErr:    true,
+		},
+		{
+			name: "Repository error",
+			id:   "1",
+			getByIDFunc: func(ctx context.Context, id int) (*models.Record, error) {
+				return nil, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/watchlist/"+tt.id, nil)
+			w := httptest.NewRecorder()
+
+			mockRepo := &mockRecordRepository{
+				getByIDFunc: tt.getByIDFunc,
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/watchlist/{id}", routes.GetRecordByID(mockRepo)).Methods(http.MethodGet)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if !tt.expectedErr {
+				var record models.Record
+				if err := json.NewDecoder(w.Body).Decode(&record); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if record.ID != 1 {
+					t.Errorf("Expected record id %d, got %d", 1, record.ID)
+				}
+			}
+		})
+	}
+}
+
 func TestUpdateRecord_Mock(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -367,6 +650,243 @@ func TestUpdateRecord_Mock(t *testing.T) {
 	}
 }
 
+func TestPatchRecord_Mock(t *testing.T) {
+	stored := models.Record{
+		ID:              1,
+		Title:           "Potemayo",
+		TotalEpisodes:   12,
+		WatchedEpisodes: 2,
+		Type:            "tv",
+		Status:          "watching",
+	}
+	patchFunc := func(ctx context.Context, id int, patch models.RecordPatch) (*models.Record, error) {
+		if id != stored.ID {
+			return nil, &repository.Error{Op: "patch record", ID: id, Kind: repository.ErrRecordNotFound}
+		}
+		if patch.Title != nil || patch.Status != nil || patch.Type != nil {
+			return nil, errors.New("unexpected columns in patch")
+		}
+		record := stored
+		patch.Apply(&record)
+		return &record, nil
+	}
+	getByIDFunc := func(ctx context.Context, id int) (*models.Record, error) {
+		record := stored
+		return &record, nil
+	}
+
+	tests := []struct {
+		name            string
+		id              string
+		contentType     string
+		body            string
+		expectedStatus  int
+		expectedWatched int
+	}{
+		{
+			name:            "Merge patch updates only supplied fields",
+			id:              "1",
+			contentType:     "application/merge-patch+json",
+			body:            `{"watched_episodes": 8}`,
+			expectedStatus:  http.StatusOK,
+			expectedWatched: 8,
+		},
+		{
+			name:            "JSON patch replace with passing test",
+			id:              "1",
+			contentType:     "application/json-patch+json",
+			body:            `[{"op":"test","path":"/watched_episodes","value":2},{"op":"replace","path":"/watched_episodes","value":3}]`,
+			expectedStatus:  http.StatusOK,
+			expectedWatched: 3,
+		},
+		{
+			name:           "JSON patch failing test",
+			id:             "1",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"test","path":"/watched_episodes","value":5},{"op":"replace","path":"/watched_episodes","value":6}]`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "JSON patch remove is rejected",
+			id:             "1",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"remove","path":"/type"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Null member is rejected",
+			id:             "1",
+			contentType:    "application/merge-patch+json",
+			body:           `{"title": null}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "ID is not patchable",
+			id:             "1",
+			contentType:    "application/merge-patch+json",
+			body:           `{"id": 5}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unsupported content type",
+			id:             "1",
+			contentType:    "text/plain",
+			body:           `watched_episodes=8`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Record not found",
+			id:             "999",
+			contentType:    "application/merge-patch+json",
+			body:           `{"watched_episodes": 8}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/watchlist/"+tt.id, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			mockRepo := &mockRecordRepository{
+				patchFunc:   patchFunc,
+				getByIDFunc: getByIDFunc,
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/watchlist/{id}", routes.PatchRecord(mockRepo)).Methods(http.MethodPatch)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var record models.Record
+				if err := json.NewDecoder(w.Body).Decode(&record); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if record.WatchedEpisodes != tt.expectedWatched {
+					t.Errorf("Expected watched episodes %d, got %d", tt.expectedWatched, record.WatchedEpisodes)
+				}
+				if record.Title != stored.Title {
+					t.Errorf("Expected title %s to be kept, got %s", stored.Title, record.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestPatchRecord_JSONPatchTestRace(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository()
+	record := models.Record{Title: "Potemayo", TotalEpisodes: 12, WatchedEpisodes: 2, Type: "tv", Status: "watching"}
+	if err := repo.CreateRecord(ctx, &record); err != nil {
+		t.Fatalf("CreateRecord failed: %v", err)
+	}
+
+	// Another client writes between the test read and the patch.
+	mockRepo := &mockRecordRepository{
+		getByIDFunc: func(ctx context.Context, id int) (*models.Record, error) {
+			tested, err := repo.GetRecordByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := repo.IncrementProgress(ctx, id, 1); err != nil {
+				return nil, err
+			}
+			return tested, nil
+		},
+		patchFunc: repo.PatchRecord,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/watchlist/{id}", routes.PatchRecord(mockRepo)).Methods(http.MethodPatch)
+
+	body := `[{"op":"test","path":"/watched_episodes","value":2},{"op":"replace","path":"/watched_episodes","value":3}]`
+	req := httptest.NewRequest(http.MethodPatch, "/watchlist/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assertProblem(t, w, http.StatusPreconditionFailed, routes.CodePreconditionFailed)
+
+	stored, _ := repo.GetRecordByID(ctx, 1)
+	if stored.WatchedEpisodes != 3 || stored.Version != 2 {
+		t.Errorf("Expected only the concurrent increment to be stored, got %+v", stored)
+	}
+}
+
+func TestIncrementProgress_Mock(t *testing.T) {
+	progressFunc := func(ctx context.Context, id int, delta int) (*models.Record, error) {
+		if id != 1 {
+			return nil, &repository.Error{Op: "increment progress", ID: id, Kind: repository.ErrRecordNotFound}
+		}
+		return &models.Record{ID: 1, Title: "Potemayo", TotalEpisodes: 12, WatchedEpisodes: 4 + delta, Type: "tv", Status: "watching"}, nil
+	}
+
+	tests := []struct {
+		name            string
+		id              string
+		body            string
+		expectedStatus  int
+		expectedWatched int
+	}{
+		{
+			name:            "Empty body increments by one",
+			id:              "1",
+			body:            "",
+			expectedStatus:  http.StatusOK,
+			expectedWatched: 5,
+		},
+		{
+			name:            "Explicit negative delta",
+			id:              "1",
+			body:            `{"delta": -2}`,
+			expectedStatus:  http.StatusOK,
+			expectedWatched: 2,
+		},
+		{
+			name:           "Invalid body",
+			id:             "1",
+			body:           `{"delta": "two"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Record not found",
+			id:             "999",
+			body:           `{"delta": 1}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/watchlist/"+tt.id+"/progress", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			mockRepo := &mockRecordRepository{
+				progressFunc: progressFunc,
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/watchlist/{id}/progress", routes.IncrementProgress(mockRepo)).Methods(http.MethodPost)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var record models.Record
+				if err := json.NewDecoder(w.Body).Decode(&record); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if record.WatchedEpisodes != tt.expectedWatched {
+					t.Errorf("Expected watched episodes %d, got %d", tt.expectedWatched, record.WatchedEpisodes)
+				}
+			}
+		})
+	}
+}
+
 func TestDeleteRecord_Mock(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -394,6 +914,14 @@ func TestDeleteRecord_Mock(t *testing.T) {
 			},
 			expectedStatus: http.StatusInternalServerError,
 		},
+		{
+			name: "Record not found",
+			id:   "999",
+			deleteFunc: func(ctx context.Context, id int) error {
+				return &repository.Error{Op: "delete record", ID: id, Kind: repository.ErrRecordNotFound}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
 	}
 
 	for _, tt := range tests {
@@ -416,6 +944,130 @@ func TestDeleteRecord_Mock(t *testing.T) {
 	}
 }
 
+func TestRepositoryErrorMapping_Mock(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedDetail string
+	}{
+		{
+			name:           "Not found",
+			err:            &repository.Error{Op: "update record", ID: 7, Kind: repository.ErrRecordNotFound},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   routes.CodeNotFound,
+			expectedDetail: "Anime record with ID 7 not found",
+		},
+		{
+			name:           "Not found without an ID",
+			err:            repository.ErrRecordNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   routes.CodeNotFound,
+			expectedDetail: "Anime record not found",
+		},
+		{
+			name:           "Conflict",
+			err:            &repository.Error{Op: "update record", ID: 7, Kind: repository.ErrConflict, Err: errors.New("Error 1062: Duplicate entry")},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   routes.CodeConflict,
+		},
+		{
+			name:           "Validation",
+			err:            &repository.Error{Op: "update record", ID: 7, Kind: repository.ErrValidation, Err: errors.New("Error 1406: Data too long")},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   routes.CodeValidation,
+		},
+		{
+			name:           "Unavailable",
+			err:            &repository.Error{Op: "update record", ID: 7, Kind: repository.ErrUnavailable, Err: errors.New("dial tcp: connection refused")},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   routes.CodeUnavailable,
+		},
+		{
+			name:           "Unclassified",
+			err:            errors.New("dial tcp: connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   routes.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.Record{Title: "Bleach", Type: "tv", Status: "watching"})
+			req := httptest.NewRequest(http.MethodPut, "/watchlist/7", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			mockRepo := &mockRecordRepository{
+				updateFunc: func(ctx context.Context, record *models.Record) error { return tt.err },
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/watchlist/{id}", routes.UpdateRecord(mockRepo)).Methods(http.MethodPut)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Expected content type application/problem+json, got %s", ct)
+			}
+
+			var problem routes.Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if problem.Code != tt.expectedCode {
+				t.Errorf("Expected error code %s, got %s", tt.expectedCode, problem.Code)
+			}
+			if problem.Status != tt.expectedStatus {
+				t.Errorf("Expected problem status %d, got %d", tt.expectedStatus, problem.Status)
+			}
+			if problem.Instance != "/watchlist/7" {
+				t.Errorf("Expected instance /watchlist/7, got %s", problem.Instance)
+			}
+			if strings.Contains(problem.Detail, "Error 1") || strings.Contains(problem.Detail, "dial tcp") {
+				t.Errorf("Expected driver message to be hidden, got %q", problem.Detail)
+			}
+			if tt.expectedDetail != "" && problem.Detail != tt.expectedDetail {
+				t.Errorf("Expected detail %q, got %q", tt.expectedDetail, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestValidationProblem_Mock(t *testing.T) {
+	body, _ := json.Marshal(models.Record{TotalEpisodes: 12})
+	req := httptest.NewRequest(http.MethodPost, "/watchlist", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	mockRepo := &mockRecordRepository{
+		createFunc: func(ctx context.Context, r *models.Record) error { return nil },
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/watchlist", routes.CreateRecord(mockRepo)).Methods(http.MethodPost)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var problem routes.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if problem.Code != routes.CodeValidation {
+		t.Errorf("Expected error code %s, got %s", routes.CodeValidation, problem.Code)
+	}
+	fields := map[string]bool{}
+	for _, fe := range problem.Errors {
+		fields[fe.Field] = true
+	}
+	if !fields["title"] || !fields["status"] {
+		t.Errorf("Expected field errors for title and status, got %+v", problem.Errors)
+	}
+}
+
 // ====================================================================================================
 // NON-MOCK TESTS (Repository Tests)
 // ====================================================================================================
@@ -639,9 +1291,9 @@ func TestDeleteRecord_Database(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 		},
 		{
-			name:           "Repository error",
+			name:           "Record already deleted",
 			id:             "1",
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 		},
 	}
 
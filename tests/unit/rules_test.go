@@ -0,0 +1,306 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"golang-watchlist/internal/db"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"golang-watchlist/internal/rules"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStatusRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		spec           string
+		record         models.Record
+		expectedStatus string
+	}{
+		{
+			name:           "Finishing marks completed",
+			spec:           "complete,start",
+			record:         models.Record{TotalEpisodes: 12, WatchedEpisodes: 12, Status: "watching"},
+			expectedStatus: "completed",
+		},
+		{
+			name:           "Unknown total never completes",
+			spec:           "complete,start",
+			record:         models.Record{TotalEpisodes: 0, WatchedEpisodes: 500, Status: "watching"},
+			expectedStatus: "watching",
+		},
+		{
+			name:           "Progress on a planned record starts it",
+			spec:           "complete,start",
+			record:         models.Record{TotalEpisodes: 12, WatchedEpisodes: 1, Status: "planning"},
+			expectedStatus: "watching",
+		},
+		{
+			name:           "Planned record watched in one go completes",
+			spec:           "start,complete",
+			record:         models.Record{TotalEpisodes: 1, WatchedEpisodes: 1, Status: "planning"},
+			expectedStatus: "completed",
+		},
+		{
+			name:           "Reopen is opt-in",
+			spec:           "complete,start",
+			record:         models.Record{TotalEpisodes: 24, WatchedEpisodes: 12, Status: "completed"},
+			expectedStatus: "completed",
+		},
+		{
+			name:           "Reopen when a new season is added",
+			spec:           "reopen",
+			record:         models.Record{TotalEpisodes: 24, WatchedEpisodes: 12, Status: "completed"},
+			expectedStatus: "watching",
+		},
+		{
+			name:           "No rules",
+			spec:           "none",
+			record:         models.Record{TotalEpisodes: 12, WatchedEpisodes: 12, Status: "watching"},
+			expectedStatus: "watching",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := rules.ParseEngine(tt.spec)
+			if err != nil {
+				t.Fatalf("Failed to parse rules: %v", err)
+			}
+			record := tt.record
+			engine.Apply(&record)
+			if record.Status != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatus, record.Status)
+			}
+		})
+	}
+
+	if _, err := rules.ParseEngine("complete,binge"); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+}
+
+func TestStatusRulesRepository(t *testing.T) {
+	stored := models.Record{Title: "Potemayo", TotalEpisodes: 12, WatchedEpisodes: 11, Type: "tv", Status: "watching"}
+
+	newRouter := func() (*mux.Router, *repository.MemoryRecordRepository) {
+		repo := repository.NewMemoryRecordRepository(rules.WithEngine(rules.DefaultEngine()))
+		record := stored
+		if err := repo.CreateRecord(context.Background(), &record); err != nil {
+			t.Fatalf("CreateRecord failed: %v", err)
+		}
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo)
+		return router, repo
+	}
+	storedStatus := func(repo *repository.MemoryRecordRepository) string {
+		record, err := repo.GetRecordByID(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("GetRecordByID failed: %v", err)
+		}
+		return record.Status
+	}
+
+	t.Run("Update completes finished record", func(t *testing.T) {
+		router, repo := newRouter()
+
+		finished := stored
+		finished.WatchedEpisodes = 12
+		body, _ := json.Marshal(finished)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/watchlist/1", bytes.NewReader(body)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if status := storedStatus(repo); status != "completed" {
+			t.Errorf("Expected saved status completed, got %s", status)
+		}
+	})
+
+	t.Run("Opt-out keeps the supplied status", func(t *testing.T) {
+		router, repo := newRouter()
+
+		finished := stored
+		finished.WatchedEpisodes = 12
+		body, _ := json.Marshal(finished)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/watchlist/1?auto_status=false", bytes.NewReader(body)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if status := storedStatus(repo); status != "watching" {
+			t.Errorf("Expected saved status watching, got %s", status)
+		}
+	})
+
+	t.Run("Patch adds derived status", func(t *testing.T) {
+		router, repo := newRouter()
+
+		req := httptest.NewRequest(http.MethodPatch, "/watchlist/1", strings.NewReader(`{"watched_episodes": 12}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if status := storedStatus(repo); status != "completed" {
+			t.Errorf("Expected patch to set status completed, got %s", status)
+		}
+	})
+
+	t.Run("Progress to the last episode completes", func(t *testing.T) {
+		router, _ := newRouter()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/watchlist/1/progress", strings.NewReader(`{"delta": 1}`)))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var record models.Record
+		if err := json.NewDecoder(w.Body).Decode(&record); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if record.Status != "completed" {
+			t.Errorf("Expected status completed, got %s", record.Status)
+		}
+	})
+
+	t.Run("Invalid opt-out value", func(t *testing.T) {
+		router, _ := newRouter()
+
+		body, _ := json.Marshal(stored)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/watchlist/1?auto_status=maybe", bytes.NewReader(body)))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+// TestStatusRulesProgress checks that a derived status is stored with the
+// increment that caused it: one version and one history entry per call.
+func TestStatusRulesProgress(t *testing.T) {
+	withRules := rules.WithEngine(rules.DefaultEngine())
+	repos := map[string]func(t *testing.T) repository.RecordRepositoryInterface{
+		"Memory": func(t *testing.T) repository.RecordRepositoryInterface {
+			return repository.NewMemoryRecordRepository(withRules)
+		},
+		"SQLite": func(t *testing.T) repository.RecordRepositoryInterface {
+			database, err := db.OpenSQLite(":memory:")
+			if err != nil {
+				t.Fatalf("Failed to open SQLite database: %v", err)
+			}
+			t.Cleanup(func() { database.Close() })
+			if err := db.Migrate(database); err != nil {
+				t.Fatalf("Failed to migrate SQLite database: %v", err)
+			}
+			return repository.NewRecordRepository(database, withRules)
+		},
+	}
+
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			record := models.Record{Title: "Mushishi", TotalEpisodes: 2, Type: "tv", Status: "planning"}
+			if err := repo.CreateRecord(ctx, &record); err != nil {
+				t.Fatalf("CreateRecord failed: %v", err)
+			}
+
+			for i, want := range []string{"watching", "completed"} {
+				updated, err := repo.IncrementProgress(ctx, record.ID, 1)
+				if err != nil {
+					t.Fatalf("IncrementProgress failed: %v", err)
+				}
+				if updated.Status != want || updated.Version != i+2 {
+					t.Errorf("Expected status %s at version %d, got %+v", want, i+2, updated)
+				}
+			}
+
+			history, err := repo.GetRecordHistory(ctx, record.ID)
+			if err != nil {
+				t.Fatalf("GetRecordHistory failed: %v", err)
+			}
+			if len(history) != 3 {
+				t.Fatalf("Expected a create and two updates, got %+v", history)
+			}
+			for i, entry := range history[1:] {
+				if entry.After == nil || entry.After.Version != i+2 || entry.After.WatchedEpisodes != i+1 {
+					t.Errorf("Unexpected history entry %+v", entry)
+				}
+			}
+			if after := history[2].After; after == nil || after.Status != "completed" {
+				t.Errorf("Expected the last entry to record the derived status, got %+v", history[2])
+			}
+		})
+	}
+
+	t.Run("Route", func(t *testing.T) {
+		repo := repository.NewMemoryRecordRepository(withRules)
+		repo.CreateRecord(context.Background(), &models.Record{Title: "Mushishi", TotalEpisodes: 1, Type: "tv", Status: "planning"})
+		router := mux.NewRouter()
+		routes.RegisterRecordRoutes(router, repo)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/watchlist/1/progress", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got != `"2"` {
+			t.Errorf("Expected ETag \"2\", got %s", got)
+		}
+	})
+}
+
+// TestIncrementProgressValidatesHook checks that both backends reject an
+// increment whose before-write hook leaves the record invalid.
+func TestIncrementProgressValidatesHook(t *testing.T) {
+	hook := repository.WithBeforeWrite(func(ctx context.Context, record *models.Record) {
+		if record.WatchedEpisodes > 0 {
+			record.Status = "binge"
+		}
+	})
+	repos := map[string]func(t *testing.T) repository.RecordRepositoryInterface{
+		"Memory": func(t *testing.T) repository.RecordRepositoryInterface {
+			return repository.NewMemoryRecordRepository(hook)
+		},
+		"SQLite": func(t *testing.T) repository.RecordRepositoryInterface {
+			return repository.NewRecordRepository(migratedSQLite(t), hook)
+		},
+	}
+
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			record := models.Record{Title: "Mushishi", TotalEpisodes: 2, Type: "tv", Status: "planning"}
+			if err := repo.CreateRecord(ctx, &record); err != nil {
+				t.Fatalf("CreateRecord failed: %v", err)
+			}
+
+			if _, err := repo.IncrementProgress(ctx, record.ID, 1); !errors.Is(err, repository.ErrValidation) {
+				t.Fatalf("Expected ErrValidation, got %v", err)
+			}
+			stored, err := repo.GetRecordByID(ctx, record.ID)
+			if err != nil {
+				t.Fatalf("GetRecordByID failed: %v", err)
+			}
+			if stored.WatchedEpisodes != 0 || stored.Status != "planning" || stored.Version != 1 {
+				t.Errorf("Expected the record unchanged, got %+v", stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,709 @@
+package unit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"golang-watchlist/internal/rules"
+	"golang-watchlist/internal/transfer"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestReadCSV(t *testing.T) {
+	t.Run("Aliases and mapping", func(t *testing.T) {
+		input := "\ufeffName,Episodes,Seen,Type,Status,Notes\n" +
+			"Blue Lock,24,5,TV,Watching,great\n" +
+			"\n" +
+			"\"Monster, the series\",74,,tv,planning,\n"
+		rows, err := transfer.ReadCSV(strings.NewReader(input), map[string]string{"seen": "watched_episodes"})
+		if err != nil {
+			t.Fatalf("ReadCSV failed: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 rows, got %+v", rows)
+		}
+
+		want := models.Record{Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 5, Type: "TV", Status: "Watching"}
+		if rows[0].Record != want || rows[0].Line != 2 {
+			t.Errorf("Expected %+v on line 2, got %+v on line %d", want, rows[0].Record, rows[0].Line)
+		}
+		if rows[1].Line != 4 || rows[1].Record.Title != "Monster, the series" {
+			t.Errorf("Unexpected second row %+v", rows[1])
+		}
+		if rows[1].Fields[transfer.FieldWatchedEpisodes] || !rows[1].Fields[transfer.FieldTotalEpisodes] {
+			t.Errorf("Expected empty cells to be absent, got fields %v", rows[1].Fields)
+		}
+	})
+
+	t.Run("Unparsable numbers", func(t *testing.T) {
+		rows, err := transfer.ReadCSV(strings.NewReader("title,total_episodes\nBlue Lock,many\n"), nil)
+		if err != nil {
+			t.Fatalf("ReadCSV failed: %v", err)
+		}
+		if len(rows[0].Errors) != 1 || rows[0].Errors[0].Field != "total_episodes" {
+			t.Errorf("Expected a total_episodes error, got %+v", rows[0].Errors)
+		}
+	})
+
+	t.Run("Formula escaping", func(t *testing.T) {
+		records := []models.Record{
+			{ID: 1, Title: "=HYPERLINK(\"http://example.com\")", Type: "tv", Status: "planning", Version: 1},
+			{ID: 2, Title: "+Anima", Type: "tv", Status: "planning", Version: 1},
+			{ID: 3, Title: "-Zero-", Type: "tv", Status: "planning", Version: 1},
+			{ID: 4, Title: "@Home", Type: "tv", Status: "planning", Version: 1},
+			{ID: 5, Title: "'Tis the Season", Type: "tv", Status: "planning", Version: 1},
+		}
+		var out strings.Builder
+		writer := transfer.NewCSVWriter(&out)
+		if err := writer.Write(records); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+
+		lines, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse export: %v", err)
+		}
+		for i, want := range []string{`'=HYPERLINK("http://example.com")`, "'+Anima", "'-Zero-", "'@Home", "'Tis the Season"} {
+			if got := lines[i+1][1]; got != want {
+				t.Errorf("Expected title cell %q, got %q", want, got)
+			}
+		}
+
+		rows, err := transfer.ReadCSV(strings.NewReader(out.String()), nil)
+		if err != nil {
+			t.Fatalf("ReadCSV failed: %v", err)
+		}
+		for i, row := range rows {
+			if row.Record != records[i] {
+				t.Errorf("Expected %+v back, got %+v", records[i], row.Record)
+			}
+		}
+	})
+
+	t.Run("No title column", func(t *testing.T) {
+		for _, input := range []string{"", "episodes,status\n12,planning\n"} {
+			if _, err := transfer.ReadCSV(strings.NewReader(input), nil); !errors.Is(err, transfer.ErrNoTitleColumn) {
+				t.Errorf("Expected ErrNoTitleColumn for %q, got %v", input, err)
+			}
+		}
+	})
+}
+
+func TestMAL(t *testing.T) {
+	t.Run("Read", func(t *testing.T) {
+		input := `<?xml version="1.0" encoding="UTF-8" ?>
+<myanimelist>
+	<myinfo><user_name>someone</user_name><user_export_type>1</user_export_type></myinfo>
+	<anime>
+		<series_animedb_id>49596</series_animedb_id>
+		<series_title><![CDATA[Blue Lock]]></series_title>
+		<series_type>TV</series_type>
+		<series_episodes>24</series_episodes>
+		<my_watched_episodes>5</my_watched_episodes>
+		<my_score>8</my_score>
+		<my_status>Watching</my_status>
+	</anime>
+	<anime>
+		<series_title>Cowboy Bebop: Tengoku no Tobira</series_title>
+		<series_type>Movie</series_type>
+		<series_episodes>1</series_episodes>
+		<my_watched_episodes>0</my_watched_episodes>
+		<my_status>Plan to Watch</my_status>
+	</anime>
+	<anime>
+		<series_title>Nichijou</series_title>
+		<my_status>Rewatching</my_status>
+	</anime>
+</myanimelist>`
+		rows, err := transfer.ReadMAL(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("ReadMAL failed: %v", err)
+		}
+		if len(rows) != 3 {
+			t.Fatalf("Expected 3 rows, got %+v", rows)
+		}
+		want := models.Record{Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 5, Type: "tv", Status: "watching"}
+		if rows[0].Record != want || rows[0].Line != 4 {
+			t.Errorf("Expected %+v on line 4, got %+v on line %d", want, rows[0].Record, rows[0].Line)
+		}
+		if got := rows[1].Record; got.Type != "movie" || got.Status != "planning" {
+			t.Errorf("Unexpected second row %+v", got)
+		}
+		if rows[2].Fields[transfer.FieldTotalEpisodes] || len(rows[2].Errors) != 1 || rows[2].Errors[0].Field != "my_status" {
+			t.Errorf("Expected a my_status error and no episodes, got %+v", rows[2])
+		}
+	})
+
+	t.Run("Leading apostrophe", func(t *testing.T) {
+		// Only CSV cells are formula-escaped, so an apostrophe in a MAL
+		// title is part of the title.
+		input := `<myanimelist>
+	<anime>
+		<series_title>'=LOVE Live</series_title>
+		<series_type>Special</series_type>
+		<my_status>Completed</my_status>
+	</anime>
+</myanimelist>`
+		rows, err := transfer.ReadMAL(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("ReadMAL failed: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Record.Title != "'=LOVE Live" {
+			t.Errorf("Expected the title to keep its apostrophe, got %+v", rows)
+		}
+	})
+
+	t.Run("Not an export", func(t *testing.T) {
+		for _, input := range []string{"", "<watchlist><anime/></watchlist>"} {
+			if _, err := transfer.ReadMAL(strings.NewReader(input)); !errors.Is(err, transfer.ErrNotMALExport) {
+				t.Errorf("Expected ErrNotMALExport for %q, got %v", input, err)
+			}
+		}
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		records := []models.Record{
+			{Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 5, Type: "tv", Status: "watching"},
+			{Title: "Akira & <friends>", TotalEpisodes: 1, WatchedEpisodes: 1, Type: "movie", Status: "completed"},
+			{Title: "Hellsing Ultimate", Type: "ova", Status: "on-hold"},
+			{Title: "Devilman Crybaby", TotalEpisodes: 10, WatchedEpisodes: 2, Type: "ona", Status: "dropped"},
+			{Title: "Mushishi Zoku Shou: Odoro no Michi", TotalEpisodes: 1, Type: "special", Status: "planning"},
+		}
+		var out strings.Builder
+		if err := transfer.WriteMAL(&out, records); err != nil {
+			t.Fatalf("WriteMAL failed: %v", err)
+		}
+		for _, want := range []string{"<user_total_anime>5</user_total_anime>", "<user_total_onhold>1</user_total_onhold>",
+			"<series_title><![CDATA[Akira & <friends>]]></series_title>", "<my_status>Plan to Watch</my_status>"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected export to contain %s, got:\n%s", want, out.String())
+			}
+		}
+
+		rows, err := transfer.ReadMAL(strings.NewReader(out.String()))
+		if err != nil {
+			t.Fatalf("ReadMAL failed: %v", err)
+		}
+		if len(rows) != len(records) {
+			t.Fatalf("Expected %d rows, got %d", len(records), len(rows))
+		}
+		for i, row := range rows {
+			row.Record.Normalize()
+			if row.Record != records[i] || len(row.Errors) > 0 {
+				t.Errorf("Expected %+v, got %+v", records[i], row)
+			}
+		}
+	})
+}
+
+func TestNDJSON(t *testing.T) {
+	t.Run("Read", func(t *testing.T) {
+		input := `{"title":"Blue Lock","total_episodes":24,"watched_episodes":5,"type":"tv","status":"watching"}
+
+{"id":3,"watched_episodes":0,"status":null}
+{"title":"Monster",
+{"title":"Frieren","total_episodes":"28"}
+{"title":"` + strings.Repeat("x", transfer.MaxNDJSONLine) + `"}
+{"title":"Baccano"}`
+		reader := transfer.NewNDJSONReader(strings.NewReader(input))
+		var rows []transfer.Row
+		for {
+			row, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Next failed: %v", err)
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) != 6 {
+			t.Fatalf("Expected 6 rows, got %+v", rows)
+		}
+
+		want := models.Record{Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 5, Type: "tv", Status: "watching"}
+		if rows[0].Record != want || rows[0].Line != 1 {
+			t.Errorf("Expected %+v on line 1, got %+v on line %d", want, rows[0].Record, rows[0].Line)
+		}
+		if got := rows[1]; got.Line != 3 || !got.Fields[transfer.FieldWatchedEpisodes] || got.Fields[transfer.FieldStatus] || got.Fields[transfer.FieldTitle] {
+			t.Errorf("Expected zero values present and nulls absent, got %+v", got)
+		}
+		if rows[2].Malformed == "" {
+			t.Errorf("Expected line 4 to be malformed, got %+v", rows[2])
+		}
+		if got := rows[3].Errors; len(got) != 1 || got[0].Field != "total_episodes" {
+			t.Errorf("Expected a total_episodes error, got %+v", got)
+		}
+		if rows[4].Malformed == "" || rows[4].Line != 6 {
+			t.Errorf("Expected line 6 to be too long, got line %d: %q", rows[4].Line, rows[4].Malformed)
+		}
+		if rows[5].Record.Title != "Baccano" || rows[5].Line != 7 {
+			t.Errorf("Expected the last line without a newline, got %+v", rows[5])
+		}
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		records := []models.Record{
+			{ID: 1, Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 5, Type: "tv", Status: "watching", Version: 2},
+			{ID: 4, Title: "Akira", TotalEpisodes: 1, Type: "movie", Status: "planning", Version: 1},
+		}
+		var out strings.Builder
+		writer := transfer.NewNDJSONWriter(&out)
+		if err := writer.Write(records); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if lines := strings.Count(out.String(), "\n"); lines != 2 {
+			t.Fatalf("Expected 2 lines, got %q", out.String())
+		}
+
+		reader := transfer.NewNDJSONReader(strings.NewReader(out.String()))
+		for _, want := range records {
+			row, err := reader.Next()
+			if err != nil {
+				t.Fatalf("Next failed: %v", err)
+			}
+			if row.Record != want {
+				t.Errorf("Expected %+v, got %+v", want, row.Record)
+			}
+		}
+	})
+
+	t.Run("Import in batches", func(t *testing.T) {
+		var input strings.Builder
+		for i := range repository.MaxBatchSize + 10 {
+			fmt.Fprintf(&input, `{"title":"Anime %d","type":"tv","status":"planning"}`+"\n", i+1)
+		}
+		input.WriteString("not json\n")
+
+		reader := &countingReader{RowReader: transfer.NewNDJSONReader(strings.NewReader(input.String()))}
+		repo := &batchSpy{MemoryRecordRepository: repository.NewMemoryRecordRepository(), reader: reader}
+		summary, err := transfer.ImportFrom(context.Background(), repo, reader, false)
+		if err != nil {
+			t.Fatalf("ImportFrom failed: %v", err)
+		}
+		if summary.Created != repository.MaxBatchSize+10 || len(summary.Errors) != 1 || summary.Errors[0].Row != repository.MaxBatchSize+11 {
+			t.Errorf("Expected every record created and the last line reported, got %+v", summary)
+		}
+		if fmt.Sprint(repo.readBefore) != fmt.Sprintf("[%d %d]", repository.MaxBatchSize, repository.MaxBatchSize+11) {
+			t.Errorf("Expected a batch written before reading on, got batches after %v rows", repo.readBefore)
+		}
+	})
+
+	t.Run("Repeat in a later batch", func(t *testing.T) {
+		var input strings.Builder
+		for i := range repository.MaxBatchSize {
+			fmt.Fprintf(&input, `{"title":"Anime %d","type":"tv","status":"planning"}`+"\n", i+1)
+		}
+		input.WriteString(`{"title":"anime 1","watched_episodes":3,"type":"tv","status":"watching"}` + "\n")
+
+		repo := repository.NewMemoryRecordRepository()
+		summary, err := transfer.ImportFrom(context.Background(), repo, transfer.NewNDJSONReader(strings.NewReader(input.String())), false)
+		if err != nil {
+			t.Fatalf("ImportFrom failed: %v", err)
+		}
+		if summary.Created != repository.MaxBatchSize || summary.Updated != 1 || len(summary.Errors) != 0 {
+			t.Errorf("Expected the repeat to update the record the first line created, got %+v", summary)
+		}
+	})
+
+	t.Run("Errors are capped", func(t *testing.T) {
+		var input strings.Builder
+		for range transfer.MaxImportErrors + 5 {
+			input.WriteString("not json\n")
+		}
+
+		repo := repository.NewMemoryRecordRepository()
+		summary, err := transfer.ImportFrom(context.Background(), repo, transfer.NewNDJSONReader(strings.NewReader(input.String())), false)
+		if err != nil {
+			t.Fatalf("ImportFrom failed: %v", err)
+		}
+		if summary.Skipped != transfer.MaxImportErrors+5 || len(summary.Errors) != transfer.MaxImportErrors || summary.MoreErrors != 5 {
+			t.Fatalf("Expected %d errors listed and 5 more counted, got %d and %d", transfer.MaxImportErrors, len(summary.Errors), summary.MoreErrors)
+		}
+		if first, last := summary.Errors[0].Row, summary.Errors[len(summary.Errors)-1].Row; first != 1 || last != transfer.MaxImportErrors {
+			t.Errorf("Expected the earliest rows listed, got rows %d to %d", first, last)
+		}
+	})
+}
+
+// countingReader counts the rows read so far.
+type countingReader struct {
+	transfer.RowReader
+	read int
+}
+
+func (c *countingReader) Next() (transfer.Row, error) {
+	row, err := c.RowReader.Next()
+	if err == nil {
+		c.read++
+	}
+	return row, err
+}
+
+// batchSpy records how many rows had been read when each batch was
+// written.
+type batchSpy struct {
+	*repository.MemoryRecordRepository
+	reader     *countingReader
+	readBefore []int
+}
+
+func (b *batchSpy) ApplyBatch(ctx context.Context, ops []repository.BatchOperation, atomic bool) ([]repository.BatchResult, error) {
+	b.readBefore = append(b.readBefore, b.reader.read)
+	return b.MemoryRecordRepository.ApplyBatch(ctx, ops, atomic)
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	newRepo := func(t *testing.T) *repository.MemoryRecordRepository {
+		repo := repository.NewMemoryRecordRepository()
+		for _, record := range []models.Record{
+			{Title: "Blue Lock", TotalEpisodes: 24, WatchedEpisodes: 5, Type: "tv", Status: "watching"},
+			{Title: "Monster", TotalEpisodes: 74, Type: "tv", Status: "planning"},
+		} {
+			if err := repo.CreateRecord(ctx, &record); err != nil {
+				t.Fatalf("Failed to seed record: %v", err)
+			}
+		}
+		return repo
+	}
+	input := "id,title,watched_episodes,type,status,version\n" +
+		",blue lock,12,,,\n" + // updates Blue Lock by title, keeping type and status
+		"2,Monster,0,tv,planning,1\n" + // unchanged
+		",Frieren,0,tv,planning,\n" + // created
+		",FRIEREN,3,tv,watching,\n" + // same anime as the row before
+		"9,Ghost,0,tv,planning,\n" + // unknown ID, created by title
+		",Baccano,0,tv,binging,\n" // invalid status
+
+	check := func(t *testing.T, summary *transfer.Summary) {
+		t.Helper()
+		if summary.Created != 2 || summary.Updated != 1 || summary.Skipped != 3 {
+			t.Errorf("Expected 2 created, 1 updated and 3 skipped, got %+v", summary)
+		}
+		var lines []int
+		for _, e := range summary.Errors {
+			lines = append(lines, e.Row)
+		}
+		if fmt.Sprint(lines) != "[5 7]" {
+			t.Errorf("Expected errors on rows 5 and 7, got %+v", summary.Errors)
+		}
+	}
+
+	t.Run("Apply", func(t *testing.T) {
+		repo := newRepo(t)
+		rows, _ := transfer.ReadCSV(strings.NewReader(input), nil)
+		summary, err := transfer.Import(ctx, repo, rows, false)
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		check(t, summary)
+
+		records, _ := repo.GetRecords(ctx)
+		if len(records) != 4 {
+			t.Fatalf("Expected 4 records, got %+v", records)
+		}
+		if got := records[0]; got.Title != "blue lock" || got.WatchedEpisodes != 12 || got.Status != "watching" || got.TotalEpisodes != 24 {
+			t.Errorf("Unexpected updated record %+v", got)
+		}
+		if got := records[2]; got.Title != "Frieren" {
+			t.Errorf("Expected Frieren created, got %+v", got)
+		}
+		if got := records[3]; got.ID != 4 || got.Title != "Ghost" {
+			t.Errorf("Expected Ghost created under a new ID, got %+v", got)
+		}
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		repo := newRepo(t)
+		rows, _ := transfer.ReadCSV(strings.NewReader(input), nil)
+		summary, err := transfer.Import(ctx, repo, rows, true)
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		check(t, summary)
+		if !summary.DryRun {
+			t.Error("Expected the summary to be marked as a dry run")
+		}
+		if records, _ := repo.GetRecords(ctx); len(records) != 2 || records[0].WatchedEpisodes != 5 {
+			t.Errorf("Expected nothing written, got %+v", records)
+		}
+	})
+
+	t.Run("Unknown ID with a known title", func(t *testing.T) {
+		repo := newRepo(t)
+		rows, _ := transfer.ReadCSV(strings.NewReader("id,title,watched_episodes,version\n9,Monster,10,7\n3,,1,\n"), nil)
+		summary, err := transfer.Import(ctx, repo, rows, false)
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		// The version belongs to the other instance's record, so it
+		// doesn't make the update conditional here.
+		if summary.Updated != 1 || summary.Created != 0 || len(summary.Errors) != 1 || summary.Errors[0].Row != 3 {
+			t.Errorf("Expected Monster updated and the row without a title skipped, got %+v", summary)
+		}
+		if got := getRecord(t, repo, 2); got.WatchedEpisodes != 10 {
+			t.Errorf("Unexpected imported record %+v", got)
+		}
+	})
+
+	t.Run("Stale version", func(t *testing.T) {
+		repo := newRepo(t)
+		rows, _ := transfer.ReadCSV(strings.NewReader("id,title,watched_episodes,version\n1,Blue Lock,20,7\n"), nil)
+		summary, err := transfer.Import(ctx, repo, rows, false)
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if summary.Updated != 0 || summary.Skipped != 1 || len(summary.Errors) != 1 || summary.Errors[0].Row != 2 {
+			t.Errorf("Expected the stale row to be skipped, got %+v", summary)
+		}
+	})
+}
+
+func TestTransferRoutes(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository(rules.WithEngine(rules.DefaultEngine()))
+	for i := range repository.MaxListLimit + 5 {
+		record := models.Record{Title: fmt.Sprintf("Anime %d", i+1), TotalEpisodes: 12, Type: "tv", Status: "planning"}
+		if err := repo.CreateRecord(ctx, &record); err != nil {
+			t.Fatalf("Failed to seed record: %v", err)
+		}
+	}
+	router := mux.NewRouter()
+	routes.RegisterRecordRoutes(router, repo)
+
+	t.Run("Export", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/export.csv", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+			t.Errorf("Expected CSV content type, got %q", ct)
+		}
+		lines, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse export: %v", err)
+		}
+		if len(lines) != repository.MaxListLimit+6 {
+			t.Fatalf("Expected a header and %d rows, got %d lines", repository.MaxListLimit+5, len(lines))
+		}
+		if strings.Join(lines[0], ",") != "id,title,total_episodes,watched_episodes,type,status,version" {
+			t.Errorf("Unexpected header %v", lines[0])
+		}
+		if strings.Join(lines[1], ",") != "1,Anime 1,12,0,tv,planning,1" {
+			t.Errorf("Unexpected first row %v", lines[1])
+		}
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/export.csv", nil))
+		exported := strings.Replace(w.Body.String(), "\n1,Anime 1,12,0,", "\n1,Anime 1,12,4,", 1)
+
+		req := httptest.NewRequest(http.MethodPost, "/watchlist/import", strings.NewReader(exported))
+		req.Header.Set("Content-Type", "text/csv")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var summary transfer.Summary
+		if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if summary.Created != 0 || summary.Updated != 1 || summary.Skipped != repository.MaxListLimit+4 {
+			t.Errorf("Expected one update, got %+v", summary)
+		}
+		// Watching an episode of a planned show starts it under the
+		// default status rules.
+		if got := getRecord(t, repo, 1); got.WatchedEpisodes != 4 || got.Status != "watching" {
+			t.Errorf("Unexpected imported record %+v", got)
+		}
+	})
+
+	t.Run("MyAnimeList", func(t *testing.T) {
+		input := `<myanimelist><anime>
+			<series_title>Anime 2</series_title><series_episodes>12</series_episodes>
+			<my_watched_episodes>12</my_watched_episodes><my_status>Completed</my_status>
+		</anime><anime>
+			<series_title>Frieren</series_title><series_type>TV</series_type><series_episodes>28</series_episodes>
+			<my_watched_episodes>0</my_watched_episodes><my_status>Plan to Watch</my_status>
+		</anime></myanimelist>`
+		req := httptest.NewRequest(http.MethodPost, "/watchlist/import", strings.NewReader(input))
+		req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var summary transfer.Summary
+		if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if summary.Created != 1 || summary.Updated != 1 || len(summary.Errors) != 0 {
+			t.Errorf("Expected one create and one update, got %+v", summary)
+		}
+		if got := getRecord(t, repo, 2); got.Status != "completed" || got.WatchedEpisodes != 12 {
+			t.Errorf("Unexpected imported record %+v", got)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/export.xml", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+			t.Errorf("Expected XML content type, got %q", ct)
+		}
+		rows, err := transfer.ReadMAL(w.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse export: %v", err)
+		}
+		if len(rows) != repository.MaxListLimit+6 {
+			t.Errorf("Expected %d entries, got %d", repository.MaxListLimit+6, len(rows))
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/export.ndjson", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Expected NDJSON content type, got %q", ct)
+		}
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		if len(lines) != repository.MaxListLimit+6 {
+			t.Fatalf("Expected %d lines, got %d", repository.MaxListLimit+6, len(lines))
+		}
+		var first models.Record
+		if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.ID != 1 {
+			t.Fatalf("Expected record 1 on the first line, got %q: %v", lines[0], err)
+		}
+
+		lines[0] = strings.Replace(lines[0], `"watched_episodes":4`, `"watched_episodes":6`, 1)
+		lines[1] = "{oops"
+		req := httptest.NewRequest(http.MethodPost, "/watchlist/import", strings.NewReader(strings.Join(lines, "\n")))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var summary transfer.Summary
+		if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if summary.Updated != 1 || len(summary.Errors) != 1 || summary.Errors[0].Row != 2 {
+			t.Errorf("Expected one update and an error on line 2, got %+v", summary)
+		}
+		if got := getRecord(t, repo, 1); got.WatchedEpisodes != 6 {
+			t.Errorf("Unexpected imported record %+v", got)
+		}
+	})
+
+	t.Run("CSV into an empty list", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/export.csv", nil))
+		exported := w.Body.String()
+
+		empty := repository.NewMemoryRecordRepository()
+		target := mux.NewRouter()
+		routes.RegisterRecordRoutes(target, empty)
+		summary := importFile(t, target, "text/csv", exported)
+		want := strings.Count(exported, "\n") - 1
+		if summary.Created != want || summary.Skipped != 0 {
+			t.Errorf("Expected %d records created, got %+v", want, summary)
+		}
+		if got := getRecord(t, empty, 2); got.Title != "Anime 2" || got.Status != "completed" {
+			t.Errorf("Unexpected imported record %+v", got)
+		}
+	})
+
+	t.Run("NDJSON into an empty list", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/export.ndjson", nil))
+		exported := w.Body.String()
+
+		empty := repository.NewMemoryRecordRepository()
+		target := mux.NewRouter()
+		routes.RegisterRecordRoutes(target, empty)
+		summary := importFile(t, target, "application/x-ndjson", exported)
+		want := strings.Count(exported, "\n")
+		if summary.Created != want || summary.Skipped != 0 {
+			t.Errorf("Expected %d records created, got %+v", want, summary)
+		}
+		if got := getRecord(t, empty, 1); got.Title != "Anime 1" || got.WatchedEpisodes != 6 || got.Version != 1 {
+			t.Errorf("Unexpected imported record %+v", got)
+		}
+
+		// Importing the same export again matches every row by ID.
+		if summary := importFile(t, target, "application/x-ndjson", exported); summary.Created != 0 || summary.Skipped != want {
+			t.Errorf("Expected every row to match, got %+v", summary)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			target      string
+			contentType string
+			body        string
+			status      int
+		}{
+			{"Unsupported format", "/watchlist/import", "application/pdf", "title\nx\n", http.StatusUnsupportedMediaType},
+			{"Bad mapping", "/watchlist/import?map=Name:rating", "text/csv", "Name\nx\n", http.StatusBadRequest},
+			{"Bad dry run", "/watchlist/import?dry_run=maybe", "text/csv", "title\nx\n", http.StatusBadRequest},
+			{"No title column", "/watchlist/import", "text/csv", "rating\n5\n", http.StatusBadRequest},
+			{"Malformed CSV", "/watchlist/import", "text/csv", "title\n\"unterminated\n", http.StatusBadRequest},
+			{"Not a MyAnimeList export", "/watchlist/import", "application/xml", "<list/>", http.StatusBadRequest},
+			{"Malformed XML", "/watchlist/import", "application/xml", "<myanimelist><anime>", http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", tt.contentType)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != tt.status {
+					t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+				}
+			})
+		}
+	})
+}
+
+// importFile posts body to /watchlist/import and decodes the summary.
+func importFile(t *testing.T, router *mux.Router, contentType, body string) transfer.Summary {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/watchlist/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var summary transfer.Summary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return summary
+}
+
+func getRecord(t *testing.T, repo repository.RecordRepositoryInterface, id int) models.Record {
+	t.Helper()
+	record, err := repo.GetRecordByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get record %d: %v", id, err)
+	}
+	return *record
+}
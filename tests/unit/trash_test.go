@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/repository"
+	"golang-watchlist/internal/routes"
+	"golang-watchlist/internal/trash"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTrashRoutes_Mock(t *testing.T) {
+	deletedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo := &mockRecordRepository{
+		getByIDFunc: func(ctx context.Context, id int) (*models.Record, error) {
+			return nil, &repository.Error{Op: "get record", ID: id, Kind: repository.ErrRecordNotFound}
+		},
+		trashFunc: func(ctx context.Context) ([]models.TrashedRecord, error) {
+			return []models.TrashedRecord{{
+				Record:    models.Record{ID: 3, Title: "Kaiba", Type: "tv", Status: "dropped", Version: 2},
+				DeletedAt: deletedAt,
+			}}, nil
+		},
+		restoreFunc: func(ctx context.Context, id int) (*models.Record, error) {
+			if id != 3 {
+				return nil, &repository.Error{Op: "restore record", ID: id, Kind: repository.ErrRecordNotFound}
+			}
+			return &models.Record{ID: 3, Title: "Kaiba", Type: "tv", Status: "dropped", Version: 3}, nil
+		},
+	}
+	router := mux.NewRouter()
+	routes.RegisterRecordRoutes(router, mockRepo)
+
+	t.Run("List trash", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/watchlist/trash", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var records []models.TrashedRecord
+		if err := json.NewDecoder(w.Body).Decode(&records); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(records) != 1 || records[0].ID != 3 || !records[0].DeletedAt.Equal(deletedAt) {
+			t.Errorf("Expected record 3 deleted at %v, got %+v", deletedAt, records)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/watchlist/3/restore", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if etag := w.Header().Get("ETag"); etag != `"3"` {
+			t.Errorf("Expected ETag %q, got %q", `"3"`, etag)
+		}
+	})
+
+	t.Run("Restore record not in trash", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/watchlist/4/restore", nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("Restore invalid ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/watchlist/abc/restore", nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestTrashPurger(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRecordRepository()
+	record := models.Record{Title: "Serial Experiments Lain", Type: "tv", Status: "completed"}
+	if err := repo.CreateRecord(ctx, &record); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	if err := repo.DeleteRecord(ctx, record.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+
+	purger := &trash.Purger{Store: repo, Retention: 24 * time.Hour, Interval: time.Hour}
+	if purged, err := purger.PurgeOnce(ctx, time.Now()); err != nil || purged != 0 {
+		t.Errorf("Expected a fresh deletion to be kept, got %d, %v", purged, err)
+	}
+	if purged, err := purger.PurgeOnce(ctx, time.Now().Add(25*time.Hour)); err != nil || purged != 1 {
+		t.Errorf("Expected an expired deletion to be purged, got %d, %v", purged, err)
+	}
+}
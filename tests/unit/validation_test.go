@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"golang-watchlist/internal/models"
+	"golang-watchlist/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func fieldsOf(err error) []string {
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) {
+		return nil
+	}
+	var fields []string
+	for _, fe := range verr.Errors {
+		fields = append(fields, fe.Field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestRecordValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		record         models.Record
+		expectedFields []string
+	}{
+		{
+			name: "Valid record",
+			record: models.Record{
+				Title:           "Attack on Titan",
+				TotalEpisodes:   25,
+				WatchedEpisodes: 24,
+				Type:            "tv",
+				Status:          "watching",
+			},
+		},
+		{
+			name: "Unknown episode count allows any progress",
+			record: models.Record{
+				Title:           "One Piece",
+				TotalEpisodes:   0,
+				WatchedEpisodes: 1100,
+				Type:            "tv",
+				Status:          "watching",
+			},
+		},
+		{
+			name: "Mixed case is normalized",
+			record: models.Record{
+				Title:  "  Your Name  ",
+				Type:   "Movie",
+				Status: "COMPLETED",
+			},
+		},
+		{
+			name:           "Empty record reports every required field",
+			record:         models.Record{},
+			expectedFields: []string{"status", "title", "type"},
+		},
+		{
+			name: "Enums and bounds",
+			record: models.Record{
+				Title:           "Bleach",
+				TotalEpisodes:   -1,
+				WatchedEpisodes: -5,
+				Type:            "music",
+				Status:          "binging",
+			},
+			expectedFields: []string{"status", "total_episodes", "type", "watched_episodes"},
+		},
+		{
+			name: "Watched exceeds total",
+			record: models.Record{
+				Title:           "Bleach",
+				TotalEpisodes:   366,
+				WatchedEpisodes: 367,
+				Type:            "tv",
+				Status:          "watching",
+			},
+			expectedFields: []string{"watched_episodes"},
+		},
+		{
+			name: "Title too long",
+			record: models.Record{
+				Title:  strings.Repeat("あ", models.MaxTitleLength+1),
+				Type:   "tv",
+				Status: "planning",
+			},
+			expectedFields: []string{"title"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			record.Normalize()
+			fields := fieldsOf(record.Validate())
+			if strings.Join(fields, ",") != strings.Join(tt.expectedFields, ",") {
+				t.Errorf("Expected field errors %v, got %v", tt.expectedFields, fields)
+			}
+		})
+	}
+}
+
+func TestRecordPatchValidate(t *testing.T) {
+	status := "binging"
+	total, watched := 12, 13
+	patch := models.RecordPatch{Status: &status, TotalEpisodes: &total, WatchedEpisodes: &watched}
+
+	fields := fieldsOf(patch.Validate())
+	expected := []string{"status", "watched_episodes"}
+	if strings.Join(fields, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected field errors %v, got %v", expected, fields)
+	}
+}
+
+func TestCreateRecordValidation_Mock(t *testing.T) {
+	body, _ := json.Marshal(models.Record{
+		Title:           "Bleach",
+		TotalEpisodes:   12,
+		WatchedEpisodes: 13,
+		Type:            "music",
+		Status:          "binging",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/watchlist", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	mockRepo := &mockRecordRepository{
+		createFunc: func(ctx context.Context, r *models.Record) error {
+			t.Error("Expected invalid record not to reach the repository")
+			return nil
+		},
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/watchlist", routes.CreateRecord(mockRepo)).Methods(http.MethodPost)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var problem routes.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(problem.Errors) != 3 {
+		t.Errorf("Expected 3 field errors, got %+v", problem.Errors)
+	}
+}